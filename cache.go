@@ -0,0 +1,245 @@
+package redisobj
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+
+	"github.com/go-redis/redis/v7"
+)
+
+// cacheCheck compares the hash of one cacheable struct with the hash stored in redis.
+type cacheCheck struct {
+	key     string
+	hashKey string
+	hash    string
+	result  *redis.Cmd
+	fresh   bool
+}
+
+// cacheChecks holds the checks of every cacheable struct of an object.
+// The checks are sent in a single pipeline ahead of the data commands, so an unchanged object costs one round trip.
+type cacheChecks []cacheCheck
+
+// isCacheable reports if the struct is tracked by its own existence and hash keys.
+// Cacheable structs are the root struct or are keyed.
+func (self *objStruct) isCacheable() bool {
+	return self.structData.structIndex == -1 || self.keyFieldIndex != -1
+}
+
+// FNV-1a parameters, used to hash the encoded data of objects.
+const (
+	hashOffset uint64 = 14695981039346656037
+	hashPrime  uint64 = 1099511628211
+)
+
+func hashUint(hash uint64, value uint64) uint64 {
+	for index := 0; index < 8; index++ {
+		hash ^= value & 0xff
+		hash *= hashPrime
+		value >>= 8
+	}
+
+	return hash
+}
+
+// hashBytes hashes the value prefixed with its length, so that consecutive values cannot be confused.
+func hashBytes(hash uint64, value []byte) uint64 {
+	hash = hashUint(hash, uint64(len(value)))
+	for _, c := range value {
+		hash ^= uint64(c)
+		hash *= hashPrime
+	}
+
+	return hash
+}
+
+// hashValues hashes the values the encoder holds from start onwards.
+func hashValues(hash uint64, encoder *Encoder, start int) uint64 {
+	hash = hashUint(hash, uint64(encoder.len()-start))
+	for index := start; index < encoder.len(); index++ {
+		hash = hashBytes(hash, encoder.value(index))
+	}
+
+	return hash
+}
+
+// hashObject hashes the data the struct and its nested structs store in redis, encoded as it is written.
+// Map entries are hashed independently of their order, and nil slices, maps and blobs hash differently from empty ones.
+func (self *objStruct) hashObject(encoder *Encoder, objValue reflect.Value) uint64 {
+	hash := hashOffset
+
+	start := encoder.len()
+	for _, valueField := range self.valueFields {
+		valueField.encodeValue(encoder, objValue)
+	}
+	hash = hashValues(hash, encoder, start)
+
+	for _, sliceField := range self.sliceFields {
+		if isNil(objValue.Field(sliceField.structIndex)) {
+			hash = hashUint(hash, 0)
+			continue
+		}
+
+		start := encoder.len()
+		sliceField.encodeSlice(encoder, objValue)
+		hash = hashValues(hashUint(hash, 1), encoder, start)
+	}
+
+	for _, blobField := range self.blobFields {
+		if isNil(objValue.Field(blobField.structIndex)) {
+			hash = hashUint(hash, 0)
+			continue
+		}
+
+		start := encoder.len()
+		blobField.encodeValue(encoder, objValue)
+		hash = hashValues(hashUint(hash, 1), encoder, start)
+	}
+
+	for _, mapField := range self.mapFields {
+		if objValue.Field(mapField.structIndex).IsNil() {
+			hash = hashUint(hash, 0)
+			continue
+		}
+
+		start := encoder.len()
+		mapField.encodeMap(encoder, objValue)
+
+		var entries uint64
+		for index := start; index < encoder.len(); index += 2 {
+			entries += hashBytes(hashBytes(hashOffset, encoder.value(index)), encoder.value(index+1))
+		}
+		hash = hashUint(hashUint(hashUint(hash, 1), uint64(encoder.len()-start)), entries)
+	}
+
+	for _, structField := range self.structFields {
+		hash = hashUint(hash, structField.hashObject(encoder, objValue.Field(structField.structData.structIndex)))
+	}
+
+	return hash
+}
+
+// checkCache compares the hashes of all cacheable structs of the object in a single pipeline.
+// When writing, the new hashes are set in the same pipeline, while the existence markers and the expiry of the hashes
+// are written with the data.
+func (self *objStruct) checkCache(redisClient *redis.Client, keyPrefix string, objValue reflect.Value, write bool, options Options) (cacheChecks, error) {
+	checks := cacheChecks{}
+	pipe := redisClient.Pipeline()
+	encoder := getEncoder()
+	defer putEncoder(encoder)
+
+	if err := self.addCacheChecks(pipe, &checks, encoder, keyPrefix, objValue, write, options); err != nil {
+		return nil, err
+	}
+
+	if _, err := pipe.Exec(); err != nil && err != redis.Nil {
+		return nil, fmt.Errorf("%w: %s", ErrCacheFailure, err)
+	}
+
+	for index := range checks {
+		previousHash, err := checks[index].result.Text()
+		if err != nil && err != redis.Nil {
+			return nil, fmt.Errorf("%w: %s", ErrCacheFailure, err)
+		}
+
+		checks[index].fresh = err == nil && previousHash == checks[index].hash
+		checks[index].result = nil
+	}
+
+	return checks, nil
+}
+
+func (self *objStruct) addCacheChecks(pipe redis.Pipeliner, checks *cacheChecks, encoder *Encoder, keyPrefix string, objValue reflect.Value, write bool, options Options) error {
+	key, err := self.key(keyPrefix, objValue, options)
+	if err != nil {
+		return err
+	}
+
+	if self.isCacheable() {
+		check := cacheCheck{
+			key:     key,
+			hashKey: key + ".__HASH__",
+			hash:    strconv.FormatUint(self.hashObject(encoder, objValue), 10),
+		}
+
+		if write {
+			// When writing, the hash keeps the expiry of the data it was stored with. Changed structs give it the
+			// expiry of the write along with their data.
+			check.result = pipe.Do("set", check.hashKey, check.hash, "keepttl", "get")
+		} else {
+			// When reading, just get the hash key.
+			check.result = pipe.Do("get", check.hashKey)
+		}
+
+		*checks = append(*checks, check)
+	}
+
+	for _, structField := range self.structFields {
+		objStructValue := objValue.Field(structField.structData.structIndex)
+
+		var childKeyPrefix string
+
+		// If the nested struct has a key, then treat this struct as unique data.
+		if structField.keyFieldIndex != -1 {
+			childKeyPrefix = keyPrefix
+		} else {
+			childKeyPrefix = key
+		}
+
+		if err := structField.addCacheChecks(pipe, checks, encoder, childKeyPrefix, objStructValue, write, options); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// combineEtag combines the hashes stored for every cacheable struct of an object into one etag.
+// If any of the hashes is missing, the object has no etag.
+func combineEtag(results []*redis.StringCmd) (string, error) {
+	etag := hashOffset
+	for _, result := range results {
+		hash, err := result.Result()
+		if err == redis.Nil {
+			return "", nil
+		}
+		if err != nil {
+			return "", fmt.Errorf("%w: %s", ErrCacheFailure, err)
+		}
+
+		etag = hashBytes(etag, []byte(hash))
+	}
+
+	return strconv.FormatUint(etag, 10), nil
+}
+
+// isFresh reports if the cacheable struct stored under key is unchanged.
+// Without checks, caching is disabled and nothing is fresh.
+func (self cacheChecks) isFresh(key string) bool {
+	for _, check := range self {
+		if check.key == key {
+			return check.fresh
+		}
+	}
+
+	return false
+}
+
+// invalidate deletes the hashes of all changed structs.
+// This is used after a failed write so that the next write is not skipped.
+func (self cacheChecks) invalidate(redisClient *redis.Client) {
+	if len(self) == 0 {
+		return
+	}
+
+	pipe := redisClient.Pipeline()
+	for _, check := range self {
+		if !check.fresh {
+			// Keyed structs live in different hash slots, so each hash is deleted on its own.
+			pipe.Del(check.hashKey)
+		}
+	}
+
+	_, _ = pipe.Exec()
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"fmt"
+)
+
+// basicKind describes how values of a basic Go type are formatted and parsed.
+type basicKind struct {
+	// convert is the type values are converted to before formatting and parsed as.
+	convert string
+	// format is the Encoder call appending the value, where %s is the converted value.
+	format string
+	// parse is the redisobj Decode call, where %s is the string value.
+	parse string
+}
+
+var basicKinds = map[string]basicKind{
+	"string":  {convert: "string", format: "encoder.AppendString(%s)"},
+	"bool":    {convert: "bool", format: "encoder.AppendBool(%s)", parse: "redisobj.DecodeBool(%s)"},
+	"int":     {convert: "int64", format: "encoder.AppendInt(%s)", parse: "redisobj.DecodeInt(%s, strconv.IntSize)"},
+	"int8":    {convert: "int64", format: "encoder.AppendInt(%s)", parse: "redisobj.DecodeInt(%s, 8)"},
+	"int16":   {convert: "int64", format: "encoder.AppendInt(%s)", parse: "redisobj.DecodeInt(%s, 16)"},
+	"int32":   {convert: "int64", format: "encoder.AppendInt(%s)", parse: "redisobj.DecodeInt(%s, 32)"},
+	"rune":    {convert: "int64", format: "encoder.AppendInt(%s)", parse: "redisobj.DecodeInt(%s, 32)"},
+	"int64":   {convert: "int64", format: "encoder.AppendInt(%s)", parse: "redisobj.DecodeInt(%s, 64)"},
+	"uint":    {convert: "uint64", format: "encoder.AppendUint(%s)", parse: "redisobj.DecodeUint(%s, strconv.IntSize)"},
+	"uint8":   {convert: "uint64", format: "encoder.AppendUint(%s)", parse: "redisobj.DecodeUint(%s, 8)"},
+	"byte":    {convert: "uint64", format: "encoder.AppendUint(%s)", parse: "redisobj.DecodeUint(%s, 8)"},
+	"uint16":  {convert: "uint64", format: "encoder.AppendUint(%s)", parse: "redisobj.DecodeUint(%s, 16)"},
+	"uint32":  {convert: "uint64", format: "encoder.AppendUint(%s)", parse: "redisobj.DecodeUint(%s, 32)"},
+	"uint64":  {convert: "uint64", format: "encoder.AppendUint(%s)", parse: "redisobj.DecodeUint(%s, 64)"},
+	"float32": {convert: "float64", format: "encoder.AppendFloat(%s, 32)", parse: "redisobj.DecodeFloat(%s, 32)"},
+	"float64": {convert: "float64", format: "encoder.AppendFloat(%s, 64)", parse: "redisobj.DecodeFloat(%s, 64)"},
+}
+
+// basicType is a field type that resolves to a basic Go type.
+type basicType struct {
+	basicKind
+	// goType is the type as declared on the field, which may be a named type.
+	goType string
+}
+
+// encode returns a statement appending the value to the encoder.
+func (self basicType) encode(value string) string {
+	return fmt.Sprintf(self.format, conversion(self.convert, self.goType, value))
+}
+
+// decode returns statements that parse source into target.
+// Values are parsed by the Decode functions shared with reflection based decoding.
+func (self basicType) decode(target string, source string) string {
+	if self.parse == "" {
+		return fmt.Sprintf("%s = %s\n", target, conversion(self.goType, self.convert, source))
+	}
+
+	return fmt.Sprintf(`if parsed, err := %[1]s; err != nil {
+		return err
+	} else {
+		%[2]s = %[3]s
+	}
+`, fmt.Sprintf(self.parse, source), target, conversion(self.goType, self.convert, "parsed"))
+}
+
+// conversion returns an expression converting the value of type fromType to toType.
+func conversion(toType string, fromType string, value string) string {
+	if toType == fromType {
+		return value
+	}
+
+	return toType + "(" + value + ")"
+}
@@ -0,0 +1,321 @@
+// Command redisobj-gen generates redisobj write and read plans for struct types.
+//
+// Generated plans encode and decode struct fields without reflection. They are registered with
+// redisobj.RegisterPlan in an init function and are used automatically by every redisobj.Store.
+// Fields that cannot be generated, such as nested structs, fall back to reflection.
+//
+// Usage:
+//
+//	//go:generate go run redisobj/cmd/redisobj-gen -type Item,Group
+package main
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+const (
+	redisobjImportPath = "redisobj"
+)
+
+var (
+	errTypeNotFound = errors.New("type not found")
+	errNotStruct    = errors.New("type is not a struct")
+)
+
+func main() {
+	log.SetFlags(0)
+	log.SetPrefix("redisobj-gen: ")
+
+	typeNames := flag.String("type", "", "comma separated list of struct type names; required")
+	output := flag.String("output", "", "output file name; default <dir>/redisobj_plans.go")
+	flag.Parse()
+
+	if *typeNames == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	dir := "."
+	if flag.NArg() > 0 {
+		dir = flag.Arg(0)
+	}
+
+	outputFile := *output
+	if outputFile == "" {
+		outputFile = filepath.Join(dir, "redisobj_plans.go")
+	}
+
+	pkg, err := parsePackage(dir, os.Getenv("GOPACKAGE"), outputFile)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	src, err := generate(pkg, strings.Split(*typeNames, ","))
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if err := ioutil.WriteFile(outputFile, src, 0644); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// parsePackage parses the non test Go files of the package in dir, excluding a previously generated output file.
+func parsePackage(dir string, packageName string, outputFile string) (*ast.Package, error) {
+	outputBase := filepath.Base(outputFile)
+
+	pkgs, err := parser.ParseDir(token.NewFileSet(), dir, func(info os.FileInfo) bool {
+		return !strings.HasSuffix(info.Name(), "_test.go") && info.Name() != outputBase
+	}, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	if packageName != "" {
+		if pkg, exists := pkgs[packageName]; exists {
+			return pkg, nil
+		}
+		return nil, fmt.Errorf("package %s not found in %s", packageName, dir)
+	}
+
+	if len(pkgs) != 1 {
+		return nil, fmt.Errorf("expected one package in %s, found %d", dir, len(pkgs))
+	}
+
+	for _, pkg := range pkgs {
+		return pkg, nil
+	}
+
+	return nil, nil
+}
+
+// generate returns the formatted source of the plans of the named struct types.
+func generate(pkg *ast.Package, typeNames []string) ([]byte, error) {
+	typeSpecs := map[string]*ast.TypeSpec{}
+	for _, file := range pkg.Files {
+		for _, decl := range file.Decls {
+			genDecl, ok := decl.(*ast.GenDecl)
+			if !ok || genDecl.Tok != token.TYPE {
+				continue
+			}
+			for _, spec := range genDecl.Specs {
+				typeSpec := spec.(*ast.TypeSpec)
+				typeSpecs[typeSpec.Name.Name] = typeSpec
+			}
+		}
+	}
+
+	gen := &generator{
+		typeSpecs: typeSpecs,
+		body:      &bytes.Buffer{},
+	}
+
+	for _, typeName := range typeNames {
+		typeName = strings.TrimSpace(typeName)
+
+		typeSpec, exists := typeSpecs[typeName]
+		if !exists {
+			return nil, fmt.Errorf("%w: %s", errTypeNotFound, typeName)
+		}
+
+		structType, ok := typeSpec.Type.(*ast.StructType)
+		if !ok {
+			return nil, fmt.Errorf("%w: %s", errNotStruct, typeName)
+		}
+
+		gen.generateType(typeName, structType)
+	}
+
+	src := &bytes.Buffer{}
+	fmt.Fprintf(src, "// Code generated by redisobj-gen. DO NOT EDIT.\n\n")
+	fmt.Fprintf(src, "package %s\n\n", pkg.Name)
+	fmt.Fprintf(src, "import (\n")
+	if bytes.Contains(gen.body.Bytes(), []byte("fmt.")) {
+		fmt.Fprintf(src, "\t%q\n", "fmt")
+	}
+	fmt.Fprintf(src, "\t%q\n", redisobjImportPath)
+	if bytes.Contains(gen.body.Bytes(), []byte("strconv.")) {
+		fmt.Fprintf(src, "\t%q\n", "strconv")
+	}
+	fmt.Fprintf(src, ")\n\n")
+	fmt.Fprintf(src, "func init() {\n")
+	src.Write(gen.body.Bytes())
+	fmt.Fprintf(src, "}\n")
+
+	formatted, err := format.Source(src.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("formatting generated source: %w\n%s", err, src.Bytes())
+	}
+
+	return formatted, nil
+}
+
+type generator struct {
+	typeSpecs map[string]*ast.TypeSpec
+	body      *bytes.Buffer
+}
+
+func (self *generator) printf(format string, args ...interface{}) {
+	fmt.Fprintf(self.body, format, args...)
+}
+
+func (self *generator) generateType(typeName string, structType *ast.StructType) {
+	fieldNames := []string{}
+	fieldTypes := map[string]ast.Expr{}
+
+	for _, field := range structType.Fields.List {
+		// Embedded fields are nested structs, which are handled by reflection.
+		for _, name := range field.Names {
+			if !name.IsExported() {
+				continue
+			}
+			fieldNames = append(fieldNames, name.Name)
+			fieldTypes[name.Name] = field.Type
+		}
+	}
+	sort.Strings(fieldNames)
+
+	self.printf("redisobj.RegisterPlan((*%s)(nil), redisobj.TypePlan{\n", typeName)
+	self.printf("Fields: map[string]redisobj.FieldPlan{\n")
+
+	for _, fieldName := range fieldNames {
+		field := fmt.Sprintf("obj.(*%s).%s", typeName, fieldName)
+
+		switch fieldType := fieldTypes[fieldName].(type) {
+		case *ast.ArrayType:
+			if fieldType.Len != nil {
+				continue
+			}
+			if isByteType(fieldType.Elt) {
+				// Byte slices are stored as a single binary safe value.
+				self.printf("%q: {\n", fieldName)
+				self.printf("EncodeValue: func(obj interface{}, encoder *redisobj.Encoder) {\n")
+				self.printf("encoder.AppendBytes(%s)\n", field)
+				self.printf("},\n")
+				self.printf("DecodeValue: func(obj interface{}, value string) error {\n")
+				self.printf("%s = redisobj.DecodeBytes(value)\n", field)
+				self.printf("return nil\n")
+				self.printf("},\n")
+				self.printf("},\n")
+				continue
+			}
+			elem, ok := self.basicType(fieldType.Elt)
+			if !ok {
+				continue
+			}
+
+			self.printf("%q: {\n", fieldName)
+			self.printf("EncodeSlice: func(obj interface{}, encoder *redisobj.Encoder) {\n")
+			self.printf("for _, element := range %s {\n", field)
+			self.printf("%s\n", elem.encode("element"))
+			self.printf("}\n")
+			self.printf("},\n")
+			self.printf("DecodeSlice: func(obj interface{}, values []string) error {\n")
+			self.printf("field := make([]%s, len(values))\n", elem.goType)
+			self.printf("for index, value := range values {\n")
+			self.printf("%s", elem.decode("field[index]", "value"))
+			self.printf("}\n")
+			self.printf("%s = field\n", field)
+			self.printf("return nil\n")
+			self.printf("},\n")
+			self.printf("},\n")
+
+		case *ast.MapType:
+			key, ok := self.basicType(fieldType.Key)
+			if !ok {
+				continue
+			}
+			elem, ok := self.basicType(fieldType.Value)
+			if !ok {
+				continue
+			}
+
+			self.printf("%q: {\n", fieldName)
+			self.printf("EncodeMap: func(obj interface{}, encoder *redisobj.Encoder) {\n")
+			self.printf("for key, element := range %s {\n", field)
+			self.printf("%s\n", key.encode("key"))
+			self.printf("%s\n", elem.encode("element"))
+			self.printf("}\n")
+			self.printf("},\n")
+			self.printf("DecodeMap: func(obj interface{}, values map[string]string) error {\n")
+			self.printf("field := make(map[%s]%s, len(values))\n", key.goType, elem.goType)
+			self.printf("for readKey, readValue := range values {\n")
+			self.printf("var key %s\n", key.goType)
+			self.printf("%s", key.decode("key", "readKey"))
+			self.printf("var element %s\n", elem.goType)
+			self.printf("%s", elem.decode("element", "readValue"))
+			self.printf("field[key] = element\n")
+			self.printf("}\n")
+			self.printf("%s = field\n", field)
+			self.printf("return nil\n")
+			self.printf("},\n")
+			self.printf("},\n")
+
+		default:
+			basic, ok := self.basicType(fieldType)
+			if !ok {
+				continue
+			}
+
+			self.printf("%q: {\n", fieldName)
+			self.printf("EncodeValue: func(obj interface{}, encoder *redisobj.Encoder) {\n")
+			self.printf("%s\n", basic.encode(field))
+			self.printf("},\n")
+			self.printf("DecodeValue: func(obj interface{}, value string) error {\n")
+			self.printf("%s", basic.decode(field, "value"))
+			self.printf("return nil\n")
+			self.printf("},\n")
+			self.printf("},\n")
+		}
+	}
+
+	self.printf("},\n")
+	self.printf("})\n")
+}
+
+// isByteType reports if the type expression is byte or uint8.
+func isByteType(expr ast.Expr) bool {
+	ident, ok := expr.(*ast.Ident)
+	return ok && (ident.Name == "byte" || ident.Name == "uint8")
+}
+
+// basicType resolves the type expression to a basic type, following named types declared in the package.
+func (self *generator) basicType(expr ast.Expr) (basicType, bool) {
+	ident, ok := expr.(*ast.Ident)
+	if !ok {
+		return basicType{}, false
+	}
+
+	goType := ident.Name
+	for depth := 0; depth < 10; depth++ {
+		if kind, exists := basicKinds[ident.Name]; exists {
+			return basicType{
+				basicKind: kind,
+				goType:    goType,
+			}, true
+		}
+
+		typeSpec, exists := self.typeSpecs[ident.Name]
+		if !exists {
+			return basicType{}, false
+		}
+		if ident, ok = typeSpec.Type.(*ast.Ident); !ok {
+			return basicType{}, false
+		}
+	}
+
+	return basicType{}, false
+}
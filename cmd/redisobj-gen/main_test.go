@@ -0,0 +1,40 @@
+package main
+
+import (
+	"flag"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var update = flag.Bool("update", false, "update the golden files")
+
+func Test_generate(t *testing.T) {
+	pkg, err := parsePackage("testdata", "", "redisobj_plans.go")
+	assert.Nil(t, err)
+
+	actualSrc, err := generate(pkg, []string{"Item", "Group"})
+	assert.Nil(t, err)
+
+	goldenFile := filepath.Join("testdata", "redisobj_plans.go.golden")
+	if *update {
+		assert.Nil(t, ioutil.WriteFile(goldenFile, actualSrc, 0644))
+	}
+
+	expectedSrc, err := ioutil.ReadFile(goldenFile)
+	assert.Nil(t, err)
+	assert.Equal(t, string(expectedSrc), string(actualSrc))
+}
+
+func Test_generate_errors(t *testing.T) {
+	pkg, err := parsePackage("testdata", "", "redisobj_plans.go")
+	assert.Nil(t, err)
+
+	_, err = generate(pkg, []string{"Missing"})
+	assert.ErrorIs(t, err, errTypeNotFound)
+
+	_, err = generate(pkg, []string{"Status"})
+	assert.ErrorIs(t, err, errNotStruct)
+}
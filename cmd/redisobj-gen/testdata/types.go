@@ -0,0 +1,32 @@
+package testdata
+
+type Status string
+
+type Count Status
+
+type Item struct {
+	_        struct{} `redisobj:"type=CatalogItem"`
+	Id       string   `redisobj:"key"`
+	Name     string
+	Status   Status
+	Code     Count
+	Quantity int
+	Price    float64
+	Ratio    float32
+	Active   bool
+	Small    int8
+	Flags    uint16
+	Data     []byte
+	Tags     []string
+	Scores   []int64
+	Labels   map[string]string
+	Counts   map[int]uint
+	Group    Group
+	Owner    *string
+	internal int
+}
+
+type Group struct {
+	Id   string `redisobj:"key"`
+	Name string
+}
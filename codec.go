@@ -0,0 +1,262 @@
+package redisobj
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+)
+
+// kindCodec encodes and decodes the values of one reflect.Kind as redis strings.
+// Reflection and generated plans share the encoding of the Encoder and the Decode functions,
+// so that values round trip exactly whichever is used to write or read them.
+type kindCodec struct {
+	append func(buf []byte, value reflect.Value) []byte
+	decode func(field reflect.Value, value string) error
+}
+
+var (
+	stringCodec = kindCodec{
+		append: func(buf []byte, value reflect.Value) []byte {
+			return append(buf, value.String()...)
+		},
+		decode: func(field reflect.Value, value string) error {
+			field.SetString(value)
+			return nil
+		},
+	}
+	boolCodec = kindCodec{
+		append: func(buf []byte, value reflect.Value) []byte {
+			return strconv.AppendBool(buf, value.Bool())
+		},
+		decode: func(field reflect.Value, value string) error {
+			parsed, err := DecodeBool(value)
+			if err != nil {
+				return err
+			}
+			field.SetBool(parsed)
+			return nil
+		},
+	}
+	// The integer and float codecs take the bit size from the type, so int and uint use the platform size.
+	intCodec = kindCodec{
+		append: func(buf []byte, value reflect.Value) []byte {
+			return strconv.AppendInt(buf, value.Int(), 10)
+		},
+		decode: func(field reflect.Value, value string) error {
+			parsed, err := DecodeInt(value, field.Type().Bits())
+			if err != nil {
+				return err
+			}
+			field.SetInt(parsed)
+			return nil
+		},
+	}
+	uintCodec = kindCodec{
+		append: func(buf []byte, value reflect.Value) []byte {
+			return strconv.AppendUint(buf, value.Uint(), 10)
+		},
+		decode: func(field reflect.Value, value string) error {
+			parsed, err := DecodeUint(value, field.Type().Bits())
+			if err != nil {
+				return err
+			}
+			field.SetUint(parsed)
+			return nil
+		},
+	}
+	floatCodec = kindCodec{
+		append: func(buf []byte, value reflect.Value) []byte {
+			return strconv.AppendFloat(buf, value.Float(), 'f', -1, value.Type().Bits())
+		},
+		decode: func(field reflect.Value, value string) error {
+			parsed, err := DecodeFloat(value, field.Type().Bits())
+			if err != nil {
+				return err
+			}
+			field.SetFloat(parsed)
+			return nil
+		},
+	}
+)
+
+// bytesCodec stores []byte and [N]byte values as a single binary safe value.
+var bytesCodec = kindCodec{
+	append: func(buf []byte, value reflect.Value) []byte {
+		if value.Kind() == reflect.Slice {
+			return append(buf, value.Bytes()...)
+		}
+		if value.CanAddr() {
+			return append(buf, value.Slice(0, value.Len()).Bytes()...)
+		}
+
+		for index := 0; index < value.Len(); index++ {
+			buf = append(buf, byte(value.Index(index).Uint()))
+		}
+		return buf
+	},
+	decode: func(field reflect.Value, value string) error {
+		if value == "" {
+			field.Set(reflect.Zero(field.Type()))
+			return nil
+		}
+
+		if field.Kind() == reflect.Slice {
+			field.SetBytes([]byte(value))
+			return nil
+		}
+
+		if len(value) != field.Len() {
+			return fmt.Errorf("%w: could not set value (%s) from %d bytes", ErrInvalidFieldType, field.Type(), len(value))
+		}
+		for index := 0; index < len(value); index++ {
+			field.Index(index).SetUint(uint64(value[index]))
+		}
+		return nil
+	},
+}
+
+var kindCodecs = [...]*kindCodec{
+	reflect.String:  &stringCodec,
+	reflect.Bool:    &boolCodec,
+	reflect.Int:     &intCodec,
+	reflect.Int8:    &intCodec,
+	reflect.Int16:   &intCodec,
+	reflect.Int32:   &intCodec,
+	reflect.Int64:   &intCodec,
+	reflect.Uint:    &uintCodec,
+	reflect.Uint8:   &uintCodec,
+	reflect.Uint16:  &uintCodec,
+	reflect.Uint32:  &uintCodec,
+	reflect.Uint64:  &uintCodec,
+	reflect.Float32: &floatCodec,
+	reflect.Float64: &floatCodec,
+}
+
+// codecOf returns the codec of the kind, or nil if values of the kind cannot be stored as redis strings.
+func codecOf(kind reflect.Kind) *kindCodec {
+	if int(kind) >= len(kindCodecs) {
+		return nil
+	}
+
+	return kindCodecs[kind]
+}
+
+// codecOfType returns the codec of values of the type, or nil if they cannot be stored as redis strings.
+// Byte slices and byte arrays are stored as a single value rather than as a collection of numbers.
+func codecOfType(t reflect.Type) *kindCodec {
+	if isByteSequence(t) {
+		return &bytesCodec
+	}
+
+	return codecOf(t.Kind())
+}
+
+// isByteSequence reports if the type is a byte slice or a byte array.
+func isByteSequence(t reflect.Type) bool {
+	return (t.Kind() == reflect.Slice || t.Kind() == reflect.Array) && t.Elem().Kind() == reflect.Uint8
+}
+
+// DecodeBytes decodes a value encoded with Encoder.AppendBytes. Empty strings decode to nil.
+func DecodeBytes(value string) []byte {
+	if value == "" {
+		return nil
+	}
+
+	return []byte(value)
+}
+
+// DecodeBool parses a value encoded with Encoder.AppendBool. Empty strings decode to false.
+func DecodeBool(value string) (bool, error) {
+	if value == "" {
+		return false, nil
+	}
+
+	parsed, err := strconv.ParseBool(value)
+	if err != nil {
+		return false, fmt.Errorf("%w: could not parse value (bool) from string (%s)", ErrInvalidFieldType, value)
+	}
+
+	return parsed, nil
+}
+
+// DecodeInt parses a value encoded with Encoder.AppendInt into an integer of bitSize bits.
+// Empty strings decode to 0. Values out of range fail with ErrValueOverflow.
+func DecodeInt(value string, bitSize int) (int64, error) {
+	if value == "" {
+		return 0, nil
+	}
+
+	parsed, err := strconv.ParseInt(value, 10, bitSize)
+	if err != nil {
+		return 0, decodeError(err, "int", bitSize, value)
+	}
+
+	return parsed, nil
+}
+
+// DecodeUint parses a value encoded with Encoder.AppendUint into an unsigned integer of bitSize bits.
+// Empty strings decode to 0. Values out of range fail with ErrValueOverflow.
+func DecodeUint(value string, bitSize int) (uint64, error) {
+	if value == "" {
+		return 0, nil
+	}
+
+	parsed, err := strconv.ParseUint(value, 10, bitSize)
+	if err != nil {
+		return 0, decodeError(err, "uint", bitSize, value)
+	}
+
+	return parsed, nil
+}
+
+// DecodeFloat parses a value encoded with Encoder.AppendFloat into a float of bitSize bits.
+// Empty strings decode to 0. NaN and infinities round trip as "NaN", "+Inf" and "-Inf".
+// Finite values beyond the range of the float fail with ErrValueOverflow rather than decoding to an infinity.
+func DecodeFloat(value string, bitSize int) (float64, error) {
+	if value == "" {
+		return 0, nil
+	}
+
+	parsed, err := strconv.ParseFloat(value, bitSize)
+	if err != nil {
+		return 0, decodeError(err, "float", bitSize, value)
+	}
+
+	return parsed, nil
+}
+
+func decodeError(err error, kind string, bitSize int, value string) error {
+	if errors.Is(err, strconv.ErrRange) {
+		return fmt.Errorf("%w: value (%s) overflows %s%d", ErrValueOverflow, value, kind, bitSize)
+	}
+
+	return fmt.Errorf("%w: could not parse value (%s%d) from string (%s)", ErrInvalidFieldType, kind, bitSize, value)
+}
+
+func setFieldFromString(field reflect.Value, value string) error {
+	codec := codecOfType(field.Type())
+	if codec == nil {
+		return fmt.Errorf("%w: could not set value (%s) from string (%s)", ErrInvalidFieldType, field.Kind(), value)
+	}
+
+	return codec.decode(field, value)
+}
+
+func valueToString(value reflect.Value) (string, error) {
+	codec := codecOfType(value.Type())
+	if codec == nil {
+		return "", fmt.Errorf("%w: could not convert value to string: %v", ErrInvalidFieldType, value.Interface())
+	}
+
+	if value.Kind() == reflect.String {
+		// Strings are returned as they are, without copying them.
+		return value.String(), nil
+	}
+
+	return string(codec.append(nil, value)), nil
+}
+
+func isStringParsable(t reflect.Type) bool {
+	return codecOf(t.Kind()) != nil
+}
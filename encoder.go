@@ -0,0 +1,111 @@
+package redisobj
+
+import (
+	"reflect"
+	"strconv"
+	"sync"
+)
+
+// Encoder appends encoded field values to a reusable buffer.
+// Every Append call encodes exactly one redis value. Encoders are pooled and reused across writes,
+// so encoding a value does not allocate a string per value.
+type Encoder struct {
+	buf    []byte
+	values [][]byte
+	args   []interface{}
+}
+
+var encoderPool = sync.Pool{
+	New: func() interface{} {
+		return &Encoder{
+			buf:    make([]byte, 0, 512),
+			values: make([][]byte, 0, 32),
+			args:   make([]interface{}, 0, 32),
+		}
+	},
+}
+
+func getEncoder() *Encoder {
+	return encoderPool.Get().(*Encoder)
+}
+
+// putEncoder returns the encoder to the pool.
+// Must only be called once every command referencing its values has been executed.
+func putEncoder(encoder *Encoder) {
+	encoder.buf = encoder.buf[:0]
+	for index := range encoder.values {
+		encoder.values[index] = nil
+	}
+	encoder.values = encoder.values[:0]
+	encoder.resetArgs()
+	encoderPool.Put(encoder)
+}
+
+func (self *Encoder) AppendString(value string) {
+	start := len(self.buf)
+	self.buf = append(self.buf, value...)
+	self.commit(start)
+}
+
+// AppendBytes encodes the bytes as a single binary safe value.
+func (self *Encoder) AppendBytes(value []byte) {
+	start := len(self.buf)
+	self.buf = append(self.buf, value...)
+	self.commit(start)
+}
+
+func (self *Encoder) AppendBool(value bool) {
+	start := len(self.buf)
+	self.buf = strconv.AppendBool(self.buf, value)
+	self.commit(start)
+}
+
+func (self *Encoder) AppendInt(value int64) {
+	start := len(self.buf)
+	self.buf = strconv.AppendInt(self.buf, value, 10)
+	self.commit(start)
+}
+
+func (self *Encoder) AppendUint(value uint64) {
+	start := len(self.buf)
+	self.buf = strconv.AppendUint(self.buf, value, 10)
+	self.commit(start)
+}
+
+// AppendFloat encodes the value with the fewest digits that parse back to the same value at the given bit size.
+func (self *Encoder) AppendFloat(value float64, bitSize int) {
+	start := len(self.buf)
+	self.buf = strconv.AppendFloat(self.buf, value, 'f', -1, bitSize)
+	self.commit(start)
+}
+
+// commit records the bytes appended since start as one value.
+// The value is capacity limited so later appends can never overwrite it, even when the buffer is reallocated.
+func (self *Encoder) commit(start int) {
+	end := len(self.buf)
+	self.values = append(self.values, self.buf[start:end:end])
+}
+
+// appendValue encodes a string parsable reflect value, or a byte slice or array, with the codec of its type.
+func (self *Encoder) appendValue(value reflect.Value) {
+	start := len(self.buf)
+	self.buf = codecOfType(value.Type()).append(self.buf, value)
+	self.commit(start)
+}
+
+func (self *Encoder) len() int {
+	return len(self.values)
+}
+
+func (self *Encoder) value(index int) []byte {
+	return self.values[index]
+}
+
+// resetArgs clears the reusable command argument buffer.
+// Only pass the buffer to commands that copy their arguments.
+func (self *Encoder) resetArgs() {
+	for index := range self.args {
+		self.args[index] = nil
+	}
+	self.args = self.args[:0]
+}
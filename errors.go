@@ -2,6 +2,9 @@ package redisobj
 
 import (
 	"errors"
+	"fmt"
+	"reflect"
+	"strings"
 )
 
 var (
@@ -11,4 +14,76 @@ var (
 	ErrObjectNotFound         = errors.New("object not found")
 	ErrRedisCommandError      = errors.New("failed executing redis command")
 	ErrCacheFailure           = errors.New("failure checking redis object cache")
+	ErrInvalidKey             = errors.New("invalid object key")
+	ErrTypeNameConflict       = errors.New("type name already in use")
+	ErrTypeNotRegistered      = errors.New("type not registered")
+	ErrNotModified            = errors.New("object not modified")
+	ErrPersistFailure         = errors.New("failure persisting object")
+	ErrLoaderNotRegistered    = errors.New("loader not registered")
+	ErrMissingField           = errors.New("field missing in redis")
+	ErrUnknownField           = errors.New("field unknown to the struct")
+	// ErrObjectKnownMissing is returned for objects recorded as missing. It wraps ErrObjectNotFound.
+	ErrObjectKnownMissing = fmt.Errorf("%w: known to be missing", ErrObjectNotFound)
+	// ErrValueOverflow is returned for numbers out of the range of their field. It wraps ErrInvalidFieldType.
+	ErrValueOverflow = fmt.Errorf("%w: value out of range", ErrInvalidFieldType)
+	// ErrPartialObject is returned for objects of which some keys expired or were removed. It wraps ErrObjectNotFound.
+	ErrPartialObject = fmt.Errorf("%w: partially expired", ErrObjectNotFound)
 )
+
+// FieldError reports a field of an object that could not be decoded from redis.
+// It wraps the cause, so errors.Is matches the sentinel errors, such as ErrInvalidFieldType.
+type FieldError struct {
+	// Type is the struct type declaring the field.
+	Type reflect.Type
+	// Path is the dotted path of the field, starting at the name of the root struct.
+	Path string
+	// Key is the redis key the value was read from.
+	Key string
+	// Field is the hash field the value was read from. For slices it is the index of the element, and for maps the
+	// entry key. It is empty if the failing element is not known.
+	Field string
+	// Value is the raw value read from redis.
+	Value string
+	Err   error
+}
+
+func (self *FieldError) Error() string {
+	return fmt.Sprintf("%s: %s (key %s, field %s, value %q)", self.Err, self.Path, self.Key, self.Field, self.Value)
+}
+
+func (self *FieldError) Unwrap() error {
+	return self.Err
+}
+
+// MultiError aggregates several errors into one.
+// errors.Is and errors.As match if any of the aggregated errors match.
+type MultiError []error
+
+func (self MultiError) Error() string {
+	messages := make([]string, len(self))
+	for index, err := range self {
+		messages[index] = err.Error()
+	}
+
+	return strings.Join(messages, "; ")
+}
+
+func (self MultiError) Is(target error) bool {
+	for _, err := range self {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (self MultiError) As(target interface{}) bool {
+	for _, err := range self {
+		if errors.As(err, target) {
+			return true
+		}
+	}
+
+	return false
+}
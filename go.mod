@@ -5,6 +5,5 @@ go 1.16
 require (
 	github.com/go-redis/redis/v7 v7.4.0
 	github.com/google/uuid v1.3.0
-	github.com/mitchellh/hashstructure/v2 v2.0.2
 	github.com/stretchr/testify v1.7.0
 )
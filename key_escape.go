@@ -0,0 +1,58 @@
+package redisobj
+
+import (
+	"strings"
+)
+
+const (
+	keyEscapeChar = '%'
+	hexDigits     = "0123456789ABCDEF"
+	// emptyKeyValue stands in for empty key values. NUL bytes are never escaped, so no other key value encodes to it.
+	emptyKeyValue = "%00"
+)
+
+// isKeyByteUnsafe reports whether the byte would break the redisobj key layout if used unescaped.
+// ':' separates key segments, '{' and '}' delimit the redis hash tag, and '%' is the escape character.
+func isKeyByteUnsafe(c byte) bool {
+	switch c {
+	case ':', '{', '}', keyEscapeChar:
+		return true
+	}
+
+	return false
+}
+
+// isKeyValueSafe reports whether the key value can be used in a key without escaping.
+func isKeyValueSafe(value string) bool {
+	for i := 0; i < len(value); i++ {
+		if isKeyByteUnsafe(value[i]) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// escapeKeyValue percent encodes any byte of the key value that is unsafe to use in a redis key.
+// Values without unsafe bytes are returned unchanged, so existing keys keep their layout.
+func escapeKeyValue(value string) string {
+	if isKeyValueSafe(value) {
+		return value
+	}
+
+	var builder strings.Builder
+	builder.Grow(len(value) + 8)
+
+	for i := 0; i < len(value); i++ {
+		c := value[i]
+		if isKeyByteUnsafe(c) {
+			builder.WriteByte(keyEscapeChar)
+			builder.WriteByte(hexDigits[c>>4])
+			builder.WriteByte(hexDigits[c&0x0F])
+		} else {
+			builder.WriteByte(c)
+		}
+	}
+
+	return builder.String()
+}
@@ -0,0 +1,131 @@
+package redisobj
+
+import (
+	"context"
+	"math"
+	"math/rand"
+	"reflect"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// defaultLoadDuration is the assumed duration of a load until a load of the type has been measured.
+	defaultLoadDuration = 100 * time.Millisecond
+)
+
+// Loader loads an object from the source of truth, such as a database.
+type Loader interface {
+	// Load populates obj, whose key fields are set, from the source of truth.
+	// Return ErrObjectNotFound if the object does not exist.
+	Load(ctx context.Context, obj interface{}) error
+}
+
+// LoaderFunc adapts a function to the Loader interface.
+type LoaderFunc func(ctx context.Context, obj interface{}) error
+
+func (self LoaderFunc) Load(ctx context.Context, obj interface{}) error {
+	return self(ctx, obj)
+}
+
+// loadCall is one load in progress. Waiters copy the loaded object once ready is closed.
+type loadCall struct {
+	ready chan struct{}
+	value reflect.Value
+	err   error
+}
+
+// loadGroup coalesces concurrent loads of the same object key.
+type loadGroup struct {
+	mutex sync.Mutex
+	calls map[string]*loadCall
+}
+
+func newLoadGroup() *loadGroup {
+	return &loadGroup{
+		calls: map[string]*loadCall{},
+	}
+}
+
+// do runs load for the object key unless a load of the key is already in progress.
+// In that case, the result of the load in progress is copied into objValue instead.
+func (self *loadGroup) do(key string, objStructRef *objStruct, objValue reflect.Value, load func() error) error {
+	self.mutex.Lock()
+	if call, exists := self.calls[key]; exists {
+		self.mutex.Unlock()
+
+		<-call.ready
+		if call.err == nil {
+			objStructRef.copyObject(objValue, call.value)
+		}
+
+		return call.err
+	}
+
+	call := &loadCall{
+		ready: make(chan struct{}),
+	}
+	self.calls[key] = call
+	self.mutex.Unlock()
+
+	call.err = load()
+	if call.err == nil {
+		// The waiters get a copy, so the caller may modify the loaded object right away.
+		call.value = reflect.New(objValue.Type()).Elem()
+		objStructRef.copyObject(call.value, objValue)
+	}
+
+	self.mutex.Lock()
+	delete(self.calls, key)
+	self.mutex.Unlock()
+	close(call.ready)
+
+	return call.err
+}
+
+// registeredLoader is a Loader registered for a type, measuring how long its loads take.
+type registeredLoader struct {
+	loader Loader
+	// duration is the moving average of the load durations in nanoseconds.
+	duration int64
+}
+
+// loaderRegistry holds the Loaders registered for types, shared by a Store and its children.
+type loaderRegistry struct {
+	loaders sync.Map // reflect.Type -> *registeredLoader
+}
+
+func (self *loaderRegistry) get(objType reflect.Type) *registeredLoader {
+	if value, exists := self.loaders.Load(objType); exists {
+		return value.(*registeredLoader)
+	}
+
+	return nil
+}
+
+func (self *registeredLoader) Load(ctx context.Context, obj interface{}) error {
+	start := time.Now()
+	err := self.loader.Load(ctx, obj)
+
+	// Races between concurrent loads only lose a sample.
+	duration := int64(time.Since(start))
+	if previous := atomic.LoadInt64(&self.duration); previous != 0 {
+		duration = (previous*4 + duration) / 5
+	}
+	atomic.StoreInt64(&self.duration, duration)
+
+	return err
+}
+
+// shouldRefresh decides if an object expiring in ttl is refreshed early, using the XFetch algorithm.
+// Objects are refreshed with a probability growing exponentially as they approach expiry, scaled by the load duration
+// and the beta factor, so that a single read refreshes a hot object shortly before it expires.
+func (self *registeredLoader) shouldRefresh(ttl time.Duration, beta float64) bool {
+	duration := time.Duration(atomic.LoadInt64(&self.duration))
+	if duration == 0 {
+		duration = defaultLoadDuration
+	}
+
+	return -float64(duration)*beta*math.Log(1-rand.Float64()) >= float64(ttl)
+}
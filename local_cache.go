@@ -0,0 +1,439 @@
+package redisobj
+
+import (
+	"container/list"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis/v7"
+)
+
+const (
+	// localCacheChannelSuffix is appended to the Store namespace to form the invalidation channel.
+	localCacheChannelSuffix = ":__INVALIDATE__"
+	// localCacheRetryDelay is the delay before receiving again after the invalidation subscription failed.
+	localCacheRetryDelay = 100 * time.Millisecond
+)
+
+// localCacheEntry is a copy of an object read from redis.
+type localCacheEntry struct {
+	key     string
+	value   reflect.Value
+	keys    []string
+	expires time.Time
+}
+
+// localCache is an in-process LRU of objects in front of Store.Read, bounded by entry count and age.
+// Entries are keyed by the object key of the root struct. Writes and deletes from any Store publish the keys they change,
+// and every localCache subscribed to the channel evicts the entries using those keys.
+type localCache struct {
+	mutex   sync.Mutex
+	size    int
+	ttl     time.Duration
+	entries map[string]*list.Element
+	lru     *list.List
+	// dependents maps the keys of keyed nested structs to the keys of the entries containing them.
+	dependents map[string][]string
+	// generation is incremented on every invalidation, so reads racing an invalidation are not cached.
+	generation uint64
+	// subscribed is set while invalidations are received. Objects are not cached otherwise.
+	subscribed bool
+
+	channel   string
+	pubsub    *redis.PubSub
+	tracker   *invalidationTracker
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+func newLocalCache(size int, ttl time.Duration) *localCache {
+	return &localCache{
+		size:       size,
+		ttl:        ttl,
+		entries:    map[string]*list.Element{},
+		lru:        list.New(),
+		dependents: map[string][]string{},
+		done:       make(chan struct{}),
+	}
+}
+
+// subscribe starts evicting the entries invalidated on the channel.
+// The subscription is confirmed before returning, so that objects read afterwards are cached.
+func (self *localCache) subscribe(redisClient *redis.Client, channel string) {
+	self.channel = channel
+	self.pubsub = redisClient.Subscribe(channel)
+
+	message, err := self.pubsub.Receive()
+	self.handle(message, err)
+
+	go self.receive()
+}
+
+func (self *localCache) receive() {
+	for {
+		message, err := self.pubsub.Receive()
+
+		select {
+		case <-self.done:
+			return
+		default:
+		}
+
+		if !self.handle(message, err) {
+			time.Sleep(localCacheRetryDelay)
+		}
+	}
+}
+
+// handle processes one message received on the subscription and reports if the subscription is healthy.
+func (self *localCache) handle(message interface{}, err error) bool {
+	if err != nil {
+		// Invalidations may be missed until the subscription is established again.
+		self.setSubscribed(false)
+		return false
+	}
+
+	switch message := message.(type) {
+	case *redis.Subscription:
+		self.setSubscribed(true)
+	case *redis.Message:
+		self.invalidate(message.Payload)
+	}
+
+	return true
+}
+
+// setSubscribed changes the subscription state.
+// Entries cached before a disconnect may have missed invalidations, so they are dropped.
+func (self *localCache) setSubscribed(subscribed bool) {
+	self.mutex.Lock()
+	defer self.mutex.Unlock()
+
+	if self.subscribed == subscribed {
+		return
+	}
+
+	self.subscribed = subscribed
+	self.resetLocked()
+}
+
+// reset evicts every entry.
+func (self *localCache) reset() {
+	self.mutex.Lock()
+	defer self.mutex.Unlock()
+
+	self.resetLocked()
+}
+
+// resetLocked evicts every entry. The mutex must be held.
+func (self *localCache) resetLocked() {
+	self.generation++
+	self.entries = map[string]*list.Element{}
+	self.lru.Init()
+	self.dependents = map[string][]string{}
+}
+
+// track starts evicting the entries of the keys redis reports as changed.
+// Unlike subscribe, changes from any redis client are noticed and nothing needs to be published.
+func (self *localCache) track(redisClient *redis.Client, prefix string) {
+	self.tracker = newInvalidationTracker(redisClient.Options(), prefix, self)
+	self.tracker.start()
+}
+
+func (self *localCache) close() error {
+	var err error
+
+	self.closeOnce.Do(func() {
+		close(self.done)
+		if self.pubsub != nil {
+			err = self.pubsub.Close()
+		}
+		if self.tracker != nil {
+			self.tracker.close()
+		}
+	})
+
+	return err
+}
+
+// publish queues the invalidation of the keys to the pipeline.
+// Nothing is published when redis tracks the keys.
+func (self *localCache) publish(pipe redis.Pipeliner, keys []string) {
+	if self.channel == "" {
+		return
+	}
+
+	for _, key := range keys {
+		pipe.Publish(self.channel, key)
+	}
+}
+
+// currentGeneration returns the generation to pass to put for an object about to be read.
+func (self *localCache) currentGeneration() uint64 {
+	self.mutex.Lock()
+	defer self.mutex.Unlock()
+
+	return self.generation
+}
+
+// get copies the entry of the object into objValue.
+// keys are all the object keys of the object, starting with the key of the root struct. Entries read with different
+// keyed nested structs do not match.
+func (self *localCache) get(objStructRef *objStruct, keys []string, objValue reflect.Value) bool {
+	self.mutex.Lock()
+	defer self.mutex.Unlock()
+
+	element, exists := self.entries[keys[0]]
+	if !exists {
+		return false
+	}
+
+	entry := element.Value.(*localCacheEntry)
+	if !entry.expires.IsZero() && time.Now().After(entry.expires) {
+		self.remove(element)
+		return false
+	}
+	if entry.value.Type() != objValue.Type() || !equalKeys(entry.keys, keys) {
+		return false
+	}
+
+	self.lru.MoveToFront(element)
+	objStructRef.copyObject(objValue, entry.value)
+
+	return true
+}
+
+// put stores a copy of objValue, unless an invalidation happened since generation.
+// keys are all the object keys the entry was read from, starting with the key of the root struct it is stored under.
+// If ttl is not 0, the entry expires with the object in redis, when that is before the ttl of the cache.
+func (self *localCache) put(objStructRef *objStruct, objValue reflect.Value, keys []string, ttl time.Duration, generation uint64) {
+	key := keys[0]
+	value := reflect.New(objValue.Type()).Elem()
+	objStructRef.copyObject(value, objValue)
+
+	self.mutex.Lock()
+	defer self.mutex.Unlock()
+
+	if !self.subscribed || generation != self.generation {
+		return
+	}
+
+	if element, exists := self.entries[key]; exists {
+		self.remove(element)
+	}
+
+	entry := &localCacheEntry{
+		key:   key,
+		value: value,
+		keys:  keys,
+	}
+	if self.ttl != 0 && (ttl == 0 || self.ttl < ttl) {
+		ttl = self.ttl
+	}
+	if ttl != 0 {
+		entry.expires = time.Now().Add(ttl)
+	}
+
+	self.entries[key] = self.lru.PushFront(entry)
+	for _, dependencyKey := range keys {
+		if dependencyKey != key {
+			self.dependents[dependencyKey] = append(self.dependents[dependencyKey], key)
+		}
+	}
+
+	for self.lru.Len() > self.size {
+		self.remove(self.lru.Back())
+	}
+}
+
+// equalKeys reports if both lists hold the same keys in the same order.
+func equalKeys(keys []string, otherKeys []string) bool {
+	if len(keys) != len(otherKeys) {
+		return false
+	}
+
+	for index := range keys {
+		if keys[index] != otherKeys[index] {
+			return false
+		}
+	}
+
+	return true
+}
+
+// shortestTtl returns the shortest remaining ttl of the results, or 0 if none of the keys expire.
+func shortestTtl(results []*redis.DurationCmd) time.Duration {
+	var shortest time.Duration
+	for _, result := range results {
+		if ttl, err := result.Result(); err == nil && ttl > 0 && (shortest == 0 || ttl < shortest) {
+			shortest = ttl
+		}
+	}
+
+	return shortest
+}
+
+// invalidate evicts the entries of the keys and the entries containing them.
+func (self *localCache) invalidate(keys ...string) {
+	self.mutex.Lock()
+	defer self.mutex.Unlock()
+
+	self.generation++
+
+	for _, key := range keys {
+		if element, exists := self.entries[key]; exists {
+			self.remove(element)
+		}
+
+		// The dependents are detached first, as remove updates the dependents of the removed entries.
+		dependents := self.dependents[key]
+		delete(self.dependents, key)
+
+		for _, dependentKey := range dependents {
+			if element, exists := self.entries[dependentKey]; exists {
+				self.remove(element)
+			}
+		}
+	}
+}
+
+// remove evicts the entry of the element. The mutex must be held.
+func (self *localCache) remove(element *list.Element) {
+	entry := self.lru.Remove(element).(*localCacheEntry)
+	delete(self.entries, entry.key)
+
+	for _, dependencyKey := range entry.keys {
+		dependents := self.dependents[dependencyKey]
+		for index, dependentKey := range dependents {
+			if dependentKey == entry.key {
+				dependents = append(dependents[:index], dependents[index+1:]...)
+				break
+			}
+		}
+
+		if len(dependents) == 0 {
+			delete(self.dependents, dependencyKey)
+		} else {
+			self.dependents[dependencyKey] = dependents
+		}
+	}
+}
+
+// copyObject copies src into dst without sharing the slices and maps of src.
+func (self *objStruct) copyObject(dst reflect.Value, src reflect.Value) {
+	dst.Set(src)
+
+	for _, sliceField := range self.sliceFields {
+		// Arrays are copied with the struct.
+		srcSlice := src.Field(sliceField.structIndex)
+		if isNil(srcSlice) || srcSlice.Kind() == reflect.Array {
+			continue
+		}
+
+		dstSlice := reflect.MakeSlice(srcSlice.Type(), srcSlice.Len(), srcSlice.Len())
+		reflect.Copy(dstSlice, srcSlice)
+		dst.Field(sliceField.structIndex).Set(dstSlice)
+	}
+
+	// Byte slices are values, but share their backing array like any other slice.
+	for _, fieldsWithBytes := range [][]*reflectionData{self.valueFields, self.blobFields} {
+		for _, field := range fieldsWithBytes {
+			srcBytes := src.Field(field.structIndex)
+			if srcBytes.Kind() != reflect.Slice || srcBytes.IsNil() {
+				continue
+			}
+
+			dstBytes := reflect.MakeSlice(srcBytes.Type(), srcBytes.Len(), srcBytes.Len())
+			reflect.Copy(dstBytes, srcBytes)
+			dst.Field(field.structIndex).Set(dstBytes)
+		}
+	}
+
+	for _, mapField := range self.mapFields {
+		srcMap := src.Field(mapField.structIndex)
+		if srcMap.IsNil() {
+			continue
+		}
+
+		dstMap := reflect.MakeMapWithSize(srcMap.Type(), srcMap.Len())
+		iter := srcMap.MapRange()
+		for iter.Next() {
+			dstMap.SetMapIndex(iter.Key(), iter.Value())
+		}
+		dst.Field(mapField.structIndex).Set(dstMap)
+	}
+
+	for _, structField := range self.structFields {
+		index := structField.structData.structIndex
+		structField.copyObject(dst.Field(index), src.Field(index))
+	}
+}
+
+// objectKeys appends the keys of the root struct and of every keyed nested struct of the object.
+// These are the keys announced on invalidation.
+func (self *objStruct) objectKeys(keyPrefix string, objValue reflect.Value, options Options, keys []string) ([]string, error) {
+	key, err := self.key(keyPrefix, objValue, options)
+	if err != nil {
+		return keys, err
+	}
+
+	if self.isCacheable() {
+		keys = append(keys, key)
+	}
+
+	for _, structField := range self.structFields {
+		objStructValue := objValue.Field(structField.structData.structIndex)
+
+		var childKeyPrefix string
+
+		// If the nested struct has a key, then treat this struct as unique data.
+		if structField.keyFieldIndex != -1 {
+			childKeyPrefix = keyPrefix
+		} else {
+			childKeyPrefix = key
+		}
+
+		if keys, err = structField.objectKeys(childKeyPrefix, objStructValue, options, keys); err != nil {
+			return keys, err
+		}
+	}
+
+	return keys, nil
+}
+
+// appendFieldTtlKeys appends the keys of the fields with a ttl tag of the object, including those of keyed nested structs.
+// These keys expire without an invalidation, so cached entries must not outlive them either.
+func (self *objStruct) appendFieldTtlKeys(keyPrefix string, objValue reflect.Value, options Options, keys []string) ([]string, error) {
+	key, err := self.key(keyPrefix, objValue, options)
+	if err != nil {
+		return keys, err
+	}
+
+	for _, fields := range [][]*reflectionData{self.sliceFields, self.mapFields, self.blobFields} {
+		for _, field := range fields {
+			if field.ttl != 0 {
+				keys = append(keys, key+field.keySuffix)
+			}
+		}
+	}
+
+	for _, structField := range self.structFields {
+		objStructValue := objValue.Field(structField.structData.structIndex)
+
+		var childKeyPrefix string
+
+		// If the nested struct has a key, then treat this struct as unique data.
+		if structField.keyFieldIndex != -1 {
+			childKeyPrefix = keyPrefix
+		} else {
+			childKeyPrefix = key
+		}
+
+		if keys, err = structField.appendFieldTtlKeys(childKeyPrefix, objStructValue, options, keys); err != nil {
+			return keys, err
+		}
+	}
+
+	return keys, nil
+}
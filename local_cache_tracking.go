@@ -0,0 +1,338 @@
+package redisobj
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis/v7"
+)
+
+const (
+	// trackingChannel is the channel redis publishes invalidations of tracked keys on.
+	trackingChannel = "__redis__:invalidate"
+	// trackingHealthInterval is the interval of the health checks of the tracking connections.
+	trackingHealthInterval = 5 * time.Second
+)
+
+var errTrackingReply = errors.New("unexpected reply")
+
+// invalidationTracker evicts local cache entries using redis server-assisted client side caching.
+// One connection enables CLIENT TRACKING in broadcast mode for every key with the prefix, redirecting the
+// invalidations to a second connection subscribed to the invalidation channel.
+//
+// The connections are dialed directly, as the go-redis v7 PubSub does not support the array payloads of
+// invalidation messages.
+type invalidationTracker struct {
+	options *redis.Options
+	prefix  string
+	cache   *localCache
+
+	mutex  sync.Mutex
+	conns  map[*trackingConn]struct{}
+	closed bool
+}
+
+// trackingConn is a connection speaking the minimal subset of RESP2 needed for tracking.
+type trackingConn struct {
+	conn    net.Conn
+	reader  *bufio.Reader
+	options *redis.Options
+}
+
+func newInvalidationTracker(options *redis.Options, prefix string, cache *localCache) *invalidationTracker {
+	return &invalidationTracker{
+		options: options,
+		prefix:  prefix,
+		cache:   cache,
+		conns:   map[*trackingConn]struct{}{},
+	}
+}
+
+// start establishes tracking before returning, so that objects read afterwards are cached.
+func (self *invalidationTracker) start() {
+	redirectConn, trackedConn, err := self.connect()
+	if err == nil {
+		self.cache.setSubscribed(true)
+	}
+
+	go func() {
+		for {
+			if err == nil {
+				self.serve(redirectConn, trackedConn)
+				self.cache.setSubscribed(false)
+			}
+
+			if self.isClosed() {
+				return
+			}
+			time.Sleep(localCacheRetryDelay)
+
+			if redirectConn, trackedConn, err = self.connect(); err == nil {
+				self.cache.setSubscribed(true)
+			}
+		}
+	}()
+}
+
+// connect opens the redirect connection and enables tracking on a second connection.
+func (self *invalidationTracker) connect() (*trackingConn, *trackingConn, error) {
+	redirectConn, err := self.dial()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	clientID, err := redirectConn.command("CLIENT", "ID")
+	if err != nil {
+		self.closeConn(redirectConn)
+		return nil, nil, err
+	}
+	if _, err := redirectConn.command("SUBSCRIBE", trackingChannel); err != nil {
+		self.closeConn(redirectConn)
+		return nil, nil, err
+	}
+
+	trackedConn, err := self.dial()
+	if err != nil {
+		self.closeConn(redirectConn)
+		return nil, nil, err
+	}
+
+	if _, err := trackedConn.command("CLIENT", "TRACKING", "ON", "REDIRECT", fmt.Sprint(clientID), "BCAST", "PREFIX", self.prefix); err != nil {
+		self.closeConn(redirectConn)
+		self.closeConn(trackedConn)
+		return nil, nil, err
+	}
+
+	return redirectConn, trackedConn, nil
+}
+
+// serve evicts invalidated entries until either connection fails or the tracker is closed.
+func (self *invalidationTracker) serve(redirectConn *trackingConn, trackedConn *trackingConn) {
+	defer self.closeConn(redirectConn)
+	defer self.closeConn(trackedConn)
+
+	done := make(chan struct{})
+	defer close(done)
+
+	// Both connections are pinged, so that a silently dropped connection is noticed.
+	go func() {
+		ticker := time.NewTicker(trackingHealthInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+			}
+
+			if err := redirectConn.send("PING"); err != nil {
+				self.closeConn(redirectConn)
+				return
+			}
+			if _, err := trackedConn.command("PING"); err != nil {
+				// Closing the redirect connection ends the receive loop below.
+				self.closeConn(redirectConn)
+				return
+			}
+		}
+	}()
+
+	for {
+		_ = redirectConn.conn.SetReadDeadline(time.Now().Add(2 * trackingHealthInterval))
+
+		reply, err := redirectConn.read()
+		if err != nil {
+			return
+		}
+
+		message, _ := reply.([]interface{})
+		if len(message) != 3 || message[0] != "message" {
+			// Replies to PING.
+			continue
+		}
+
+		switch payload := message[2].(type) {
+		case nil:
+			// The database was flushed.
+			self.cache.reset()
+		case []interface{}:
+			for _, key := range payload {
+				if key, ok := key.(string); ok {
+					self.cache.invalidate(trackedObjectKey(key))
+				}
+			}
+		}
+	}
+}
+
+func (self *invalidationTracker) close() {
+	self.mutex.Lock()
+	defer self.mutex.Unlock()
+
+	self.closed = true
+	for conn := range self.conns {
+		_ = conn.conn.Close()
+	}
+	self.conns = map[*trackingConn]struct{}{}
+}
+
+func (self *invalidationTracker) closeConn(conn *trackingConn) {
+	self.mutex.Lock()
+	defer self.mutex.Unlock()
+
+	_ = conn.conn.Close()
+	delete(self.conns, conn)
+}
+
+func (self *invalidationTracker) isClosed() bool {
+	self.mutex.Lock()
+	defer self.mutex.Unlock()
+
+	return self.closed
+}
+
+func (self *invalidationTracker) dial() (*trackingConn, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), self.options.DialTimeout)
+	defer cancel()
+
+	conn, err := self.options.Dialer(ctx, self.options.Network, self.options.Addr)
+	if err != nil {
+		return nil, err
+	}
+
+	tracked := &trackingConn{
+		conn:    conn,
+		reader:  bufio.NewReader(conn),
+		options: self.options,
+	}
+
+	// Connections are registered so that closing the tracker unblocks their reads.
+	self.mutex.Lock()
+	if self.closed {
+		self.mutex.Unlock()
+		_ = conn.Close()
+		return nil, net.ErrClosed
+	}
+	self.conns[tracked] = struct{}{}
+	self.mutex.Unlock()
+
+	if self.options.Password != "" {
+		if self.options.Username != "" {
+			_, err = tracked.command("AUTH", self.options.Username, self.options.Password)
+		} else {
+			_, err = tracked.command("AUTH", self.options.Password)
+		}
+		if err != nil {
+			self.closeConn(tracked)
+			return nil, err
+		}
+	}
+
+	return tracked, nil
+}
+
+// trackedObjectKey returns the key of the object owning the redis key.
+// Every key of an object starts with the hash tag of the object, which cannot contain an escaped '}'.
+func trackedObjectKey(key string) string {
+	if end := strings.IndexByte(key, '}'); strings.HasPrefix(key, "{") && end != -1 {
+		return key[:end+1]
+	}
+
+	return key
+}
+
+// command sends the command and reads its reply.
+func (self *trackingConn) command(args ...string) (interface{}, error) {
+	if err := self.send(args...); err != nil {
+		return nil, err
+	}
+
+	_ = self.conn.SetReadDeadline(deadline(self.options.ReadTimeout))
+	return self.read()
+}
+
+func (self *trackingConn) send(args ...string) error {
+	command := make([]byte, 0, 64)
+	command = append(command, '*')
+	command = strconv.AppendInt(command, int64(len(args)), 10)
+	command = append(command, '\r', '\n')
+	for _, arg := range args {
+		command = append(command, '$')
+		command = strconv.AppendInt(command, int64(len(arg)), 10)
+		command = append(command, '\r', '\n')
+		command = append(command, arg...)
+		command = append(command, '\r', '\n')
+	}
+
+	_ = self.conn.SetWriteDeadline(deadline(self.options.WriteTimeout))
+	_, err := self.conn.Write(command)
+
+	return err
+}
+
+// read reads one reply. Error replies are returned as errors.
+func (self *trackingConn) read() (interface{}, error) {
+	line, err := self.reader.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	if len(line) < 3 || line[len(line)-2] != '\r' {
+		return nil, fmt.Errorf("%w: %q", errTrackingReply, line)
+	}
+	kind, line := line[0], line[1:len(line)-2]
+
+	switch kind {
+	case '+':
+		return line, nil
+	case '-':
+		return nil, fmt.Errorf("%w: %s", ErrRedisCommandError, line)
+	case ':':
+		return strconv.ParseInt(line, 10, 64)
+	case '$':
+		length, err := strconv.Atoi(line)
+		if err != nil || length < 0 {
+			return nil, err
+		}
+
+		value := make([]byte, length+2)
+		if _, err := io.ReadFull(self.reader, value); err != nil {
+			return nil, err
+		}
+
+		return string(value[:length]), nil
+	case '*':
+		length, err := strconv.Atoi(line)
+		if err != nil || length < 0 {
+			return nil, err
+		}
+
+		values := make([]interface{}, length)
+		for index := range values {
+			if values[index], err = self.read(); err != nil {
+				return nil, err
+			}
+		}
+
+		return values, nil
+	}
+
+	return nil, fmt.Errorf("%w: %q", errTrackingReply, line)
+}
+
+// deadline returns the deadline for a socket timeout. A timeout of 0 means no timeout.
+func deadline(timeout time.Duration) time.Time {
+	if timeout <= 0 {
+		return time.Time{}
+	}
+
+	return time.Now().Add(timeout)
+}
@@ -0,0 +1,392 @@
+package redisobj
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/gob"
+	"encoding/hex"
+	"fmt"
+	"reflect"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis/v7"
+)
+
+const (
+	defaultPersistBatchSize     = 100
+	defaultPersistFlushInterval = time.Second
+	defaultPersistMaxRetries    = 3
+	defaultPersistRetryDelay    = 100 * time.Millisecond
+
+	// persistLockTtl bounds how long a crashed Store blocks other Stores from draining a write-behind queue.
+	persistLockTtl = 30 * time.Second
+)
+
+// persistTrimScript removes the persisted entries from the tail of the queue if the lock is still held.
+var persistTrimScript = redis.NewScript(`
+if redis.call("get", KEYS[2]) ~= ARGV[1] then
+	return 0
+end
+redis.call("ltrim", KEYS[1], 0, -1 - tonumber(ARGV[2]))
+redis.call("pexpire", KEYS[2], ARGV[3])
+return 1
+`)
+
+// persistUnlockScript releases the lock if it is still held.
+var persistUnlockScript = redis.NewScript(`
+if redis.call("get", KEYS[1]) == ARGV[1] then
+	return redis.call("del", KEYS[1])
+end
+return 0
+`)
+
+// Persister persists objects written to the Store to the primary database.
+// Write is called with the written object, or with a pointer to a copy of it when persisting behind the write.
+// Write-behind persists objects at least once, so persisting an object again must be harmless.
+type Persister interface {
+	Writer
+}
+
+// BatchWriter is implemented by Persisters that persist several objects at once.
+// Write-behind batches are persisted with a single WriteBatch call instead of one Write per object.
+type BatchWriter interface {
+	WriteBatch(objs []interface{}) error
+}
+
+// PersistMode selects when written objects are persisted.
+type PersistMode int
+
+const (
+	// WriteThrough persists objects in Store.Write, before they are written to redis.
+	// If persisting fails, the object is not written to redis and the error is returned.
+	WriteThrough PersistMode = iota
+	// WriteBehind queues written objects in a durable redis list, in the same pipeline as their data.
+	// The queue is drained in the background in batches.
+	WriteBehind
+)
+
+// PersistOptions configures how a Persister is attached to a type.
+type PersistOptions struct {
+	Mode PersistMode
+	// BatchSize is the maximum number of objects persisted at once. Defaults to 100.
+	BatchSize int
+	// FlushInterval is how often an empty queue is checked for new objects. Defaults to 1 second.
+	FlushInterval time.Duration
+	// MaxRetries is how often a failed batch is retried before it is moved to the failed queue. Defaults to 3.
+	MaxRetries int
+	// RetryDelay is the delay before the first retry, doubled for every further retry. Defaults to 100 milliseconds.
+	RetryDelay time.Duration
+	// OnError is called with every error of the background drain, wrapped in ErrPersistFailure.
+	OnError func(err error)
+}
+
+// persistence is a Persister attached to a type.
+type persistence struct {
+	persister    Persister
+	options      PersistOptions
+	objStructRef *objStruct
+
+	// The write-behind queue and its drain.
+	redisClient *redis.Client
+	queueKey    string
+	failedKey   string
+	lockKey     string
+	lockToken   string
+	stop        chan struct{}
+	stopped     chan struct{}
+	stopOnce    sync.Once
+	flushErr    error
+}
+
+// persisterRegistry holds the Persisters attached to types, shared by a Store and its children.
+type persisterRegistry struct {
+	persisters sync.Map // reflect.Type -> *persistence
+	// attachMutex serializes attaching Persisters, so that every replaced Persister is stopped exactly once.
+	attachMutex sync.Mutex
+}
+
+func (self *persisterRegistry) get(objType reflect.Type) *persistence {
+	if value, exists := self.persisters.Load(objType); exists {
+		return value.(*persistence)
+	}
+
+	return nil
+}
+
+// AttachPersister persists every object of the type of obj written to the Store, or to its child stores, with the
+// persister. Write-behind queues are named after the namespace of this Store and the type.
+// Attaching another Persister to the same type replaces the previous one. The previous Persister is stopped like
+// with Close, flushing its write-behind queue, and the error of the flush is returned.
+func (self *Store) AttachPersister(obj interface{}, persister Persister, options PersistOptions) error {
+	objType, err := structType(obj)
+	if err != nil {
+		return err
+	}
+
+	objStructRef, err := self.types.register(objType, "")
+	if err != nil {
+		return err
+	}
+
+	if options.BatchSize <= 0 {
+		options.BatchSize = defaultPersistBatchSize
+	}
+	if options.FlushInterval <= 0 {
+		options.FlushInterval = defaultPersistFlushInterval
+	}
+	if options.MaxRetries < 0 {
+		options.MaxRetries = 0
+	} else if options.MaxRetries == 0 {
+		options.MaxRetries = defaultPersistMaxRetries
+	}
+	if options.RetryDelay <= 0 {
+		options.RetryDelay = defaultPersistRetryDelay
+	}
+
+	persistenceRef := &persistence{
+		persister:    persister,
+		options:      options,
+		objStructRef: objStructRef,
+	}
+
+	if options.Mode == WriteBehind {
+		queueKey := "{" + self.namespace + ":__PERSIST__:" + objStructRef.structData.objName + "}"
+
+		persistenceRef.redisClient = self.redisClient
+		persistenceRef.queueKey = queueKey
+		persistenceRef.failedKey = queueKey + ".__FAILED__"
+		persistenceRef.lockKey = queueKey + ".__LOCK__"
+		persistenceRef.lockToken = newPersistLockToken()
+		persistenceRef.stop = make(chan struct{})
+		persistenceRef.stopped = make(chan struct{})
+
+		go persistenceRef.drain()
+	}
+
+	self.persisters.attachMutex.Lock()
+	previous, replaced := self.persisters.persisters.Load(objType)
+	self.persisters.persisters.Store(objType, persistenceRef)
+	self.persisters.attachMutex.Unlock()
+
+	if replaced {
+		return previous.(*persistence).close()
+	}
+
+	return nil
+}
+
+// newPersistLockToken returns a random token identifying the holder of a queue lock.
+func newPersistLockToken() string {
+	token := make([]byte, 16)
+	if _, err := rand.Read(token); err != nil {
+		return strconv.FormatInt(time.Now().UnixNano(), 36)
+	}
+
+	return hex.EncodeToString(token)
+}
+
+// writeThrough persists the object if the Persister is attached in WriteThrough mode.
+func (self *persistence) writeThrough(obj interface{}) error {
+	if self == nil || self.options.Mode != WriteThrough {
+		return nil
+	}
+
+	if err := self.persister.Write(obj); err != nil {
+		return fmt.Errorf("%w: %s", ErrPersistFailure, err)
+	}
+
+	return nil
+}
+
+// enqueue queues a snapshot of the object if the Persister is attached in WriteBehind mode.
+func (self *persistence) enqueue(pipe redis.Pipeliner, objValue reflect.Value) error {
+	if self == nil || self.options.Mode != WriteBehind {
+		return nil
+	}
+
+	buffer := bytes.Buffer{}
+	if err := gob.NewEncoder(&buffer).Encode(objValue.Interface()); err != nil {
+		return fmt.Errorf("%w: %s", ErrPersistFailure, err)
+	}
+
+	pipe.LPush(self.queueKey, buffer.Bytes())
+
+	return nil
+}
+
+// drain persists the queued objects until stopped, then flushes the queue.
+func (self *persistence) drain() {
+	defer close(self.stopped)
+
+	for {
+		persisted, err := self.drainBatch()
+		if err != nil && self.options.OnError != nil {
+			self.options.OnError(err)
+		}
+
+		select {
+		case <-self.stop:
+			self.flushErr = self.flush(err)
+			persistUnlockScript.Run(self.redisClient, []string{self.lockKey}, self.lockToken)
+			return
+		default:
+		}
+
+		if err != nil || persisted == 0 {
+			select {
+			case <-self.stop:
+			case <-time.After(self.options.FlushInterval):
+			}
+		}
+	}
+}
+
+// flush drains the queue until it is empty or fails, including objects queued while the last batch was drained.
+// err is the error of the last batch, which is reported along with the errors of the flush.
+func (self *persistence) flush(err error) error {
+	errs := MultiError{}
+	if err != nil {
+		errs = append(errs, err)
+	}
+
+	for {
+		persisted, err := self.drainBatch()
+		if err != nil {
+			if self.options.OnError != nil {
+				self.options.OnError(err)
+			}
+			errs = append(errs, err)
+			break
+		}
+		if persisted == 0 {
+			break
+		}
+	}
+
+	if len(errs) != 0 {
+		return errs
+	}
+
+	return nil
+}
+
+// drainBatch persists the oldest batch of the queue and returns the number of queue entries removed.
+// Only the Store holding the queue lock drains, so that entries are not persisted twice.
+func (self *persistence) drainBatch() (int, error) {
+	locked, err := self.redisClient.SetNX(self.lockKey, self.lockToken, persistLockTtl).Result()
+	if err != nil {
+		return 0, fmt.Errorf("%w: %s", ErrPersistFailure, err)
+	}
+	if !locked {
+		owner, err := self.redisClient.Get(self.lockKey).Result()
+		if err != nil && err != redis.Nil {
+			return 0, fmt.Errorf("%w: %s", ErrPersistFailure, err)
+		}
+		if owner != self.lockToken {
+			// Another Store is draining the queue.
+			return 0, nil
+		}
+	}
+
+	// Entries are pushed to the head, so the oldest entries are at the tail.
+	entries, err := self.redisClient.LRange(self.queueKey, int64(-self.options.BatchSize), -1).Result()
+	if err != nil {
+		return 0, fmt.Errorf("%w: %s", ErrPersistFailure, err)
+	}
+	if len(entries) == 0 {
+		return 0, nil
+	}
+
+	objs := make([]interface{}, 0, len(entries))
+	decoded := make([]interface{}, 0, len(entries))
+	var failed []interface{}
+	var errs MultiError
+	for index := len(entries) - 1; index >= 0; index-- {
+		obj := reflect.New(self.objStructRef.structData.objType)
+		if err := gob.NewDecoder(bytes.NewBufferString(entries[index])).Decode(obj.Interface()); err != nil {
+			failed = append(failed, entries[index])
+			errs = append(errs, fmt.Errorf("%w: %s", ErrPersistFailure, err))
+			continue
+		}
+
+		objs = append(objs, obj.Interface())
+		decoded = append(decoded, entries[index])
+	}
+
+	if err := self.persistBatch(objs); err != nil {
+		// Entries that could not be persisted are kept in the failed queue for inspection.
+		errs = append(errs, err)
+		failed = append(failed, decoded...)
+	}
+
+	pipe := self.redisClient.Pipeline()
+	if len(failed) != 0 {
+		pipe.LPush(self.failedKey, failed...)
+	}
+	trimmed := persistTrimScript.Eval(pipe, []string{self.queueKey, self.lockKey}, self.lockToken, len(entries), persistLockTtl.Milliseconds())
+	if _, err := pipe.Exec(); err != nil {
+		return 0, fmt.Errorf("%w: %s", ErrPersistFailure, err)
+	}
+	if trimmed.Val() != int64(1) {
+		return 0, fmt.Errorf("%w: lost the queue lock", ErrPersistFailure)
+	}
+
+	if len(errs) != 0 {
+		return len(entries), errs
+	}
+
+	return len(entries), nil
+}
+
+// persistBatch persists the objects, retrying with a doubling delay.
+func (self *persistence) persistBatch(objs []interface{}) error {
+	if len(objs) == 0 {
+		return nil
+	}
+
+	delay := self.options.RetryDelay
+	var err error
+	for attempt := 0; attempt <= self.options.MaxRetries; attempt++ {
+		if attempt != 0 {
+			time.Sleep(delay)
+			delay *= 2
+		}
+
+		if batchWriter, ok := self.persister.(BatchWriter); ok {
+			err = batchWriter.WriteBatch(objs)
+		} else {
+			err = self.writeEach(objs)
+		}
+		if err == nil {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("%w: %s", ErrPersistFailure, err)
+}
+
+func (self *persistence) writeEach(objs []interface{}) error {
+	for _, obj := range objs {
+		if err := self.persister.Write(obj); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// close stops the background drain after flushing the queue.
+func (self *persistence) close() error {
+	if self.stop == nil {
+		return nil
+	}
+
+	self.stopOnce.Do(func() {
+		close(self.stop)
+	})
+	<-self.stopped
+
+	return self.flushErr
+}
@@ -0,0 +1,196 @@
+package redisobj
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"sync"
+)
+
+// FieldPlan encodes and decodes a single struct field without reflection.
+// Plans are generated by cmd/redisobj-gen. Every function receives a pointer to the struct that owns the field.
+// Value fields implement EncodeValue and DecodeValue, slice fields EncodeSlice and DecodeSlice, and map fields EncodeMap and DecodeMap.
+// EncodeValue appends exactly one value to the Encoder, EncodeSlice one value per element,
+// and EncodeMap a key followed by its value per entry.
+type FieldPlan struct {
+	EncodeValue func(obj interface{}, encoder *Encoder)
+	DecodeValue func(obj interface{}, value string) error
+	EncodeSlice func(obj interface{}, encoder *Encoder)
+	DecodeSlice func(obj interface{}, values []string) error
+	EncodeMap   func(obj interface{}, encoder *Encoder)
+	DecodeMap   func(obj interface{}, values map[string]string) error
+}
+
+// TypePlan is the generated write and read plan of a struct type, keyed by struct field name.
+// Fields without a plan fall back to reflection.
+type TypePlan struct {
+	Fields map[string]FieldPlan
+}
+
+var typePlans = sync.Map{} // reflect.Type -> *TypePlan
+
+// RegisterPlan registers the generated plan of the struct type of obj.
+// Plans must be registered before the type is first used by a Store, which generated code does in an init function.
+func RegisterPlan(obj interface{}, plan TypePlan) {
+	objType, err := structType(obj)
+	if err != nil {
+		panic(err)
+	}
+
+	typePlans.Store(objType, &plan)
+}
+
+// lookupFieldPlan returns the generated plan of the struct field, or nil if the field should use reflection.
+func lookupFieldPlan(objType reflect.Type, fieldName string, hasPlan func(plan *FieldPlan) bool) *FieldPlan {
+	value, exists := typePlans.Load(objType)
+	if !exists {
+		return nil
+	}
+
+	fieldPlan, exists := value.(*TypePlan).Fields[fieldName]
+	if !exists || !hasPlan(&fieldPlan) {
+		return nil
+	}
+
+	return &fieldPlan
+}
+
+func hasValuePlan(plan *FieldPlan) bool {
+	return plan.EncodeValue != nil && plan.DecodeValue != nil
+}
+
+func hasSlicePlan(plan *FieldPlan) bool {
+	return plan.EncodeSlice != nil && plan.DecodeSlice != nil
+}
+
+func hasMapPlan(plan *FieldPlan) bool {
+	return plan.EncodeMap != nil && plan.DecodeMap != nil
+}
+
+func (self *reflectionData) encodeValue(encoder *Encoder, objValue reflect.Value) {
+	if self.plan != nil {
+		self.plan.EncodeValue(objValue.Addr().Interface(), encoder)
+		return
+	}
+
+	encoder.appendValue(objValue.Field(self.structIndex))
+}
+
+func (self *reflectionData) decodeValue(objValue reflect.Value, value string) error {
+	if self.plan != nil {
+		return self.plan.DecodeValue(objValue.Addr().Interface(), value)
+	}
+
+	return setFieldFromString(objValue.Field(self.structIndex), value)
+}
+
+func (self *reflectionData) encodeSlice(encoder *Encoder, objValue reflect.Value) {
+	if self.plan != nil {
+		self.plan.EncodeSlice(objValue.Addr().Interface(), encoder)
+		return
+	}
+
+	sliceField := objValue.Field(self.structIndex)
+	for index := 0; index < sliceField.Len(); index++ {
+		encoder.appendValue(sliceField.Index(index))
+	}
+}
+
+// decodeSlice decodes the elements of a slice or array field.
+// Arrays fail with ErrInvalidFieldType if the number of elements does not match the length of the array.
+// Every element that fails to decode is reported in a MultiError of FieldErrors, identified by its index.
+func (self *reflectionData) decodeSlice(objValue reflect.Value, values []string) error {
+	if self.plan != nil {
+		return self.plan.DecodeSlice(objValue.Addr().Interface(), values)
+	}
+
+	var errs MultiError
+
+	sliceField := objValue.Field(self.structIndex)
+	if sliceField.Kind() == reflect.Array {
+		// Arrays without stored elements, such as expired fields with a ttl tag, are read as empty.
+		if len(values) == 0 {
+			sliceField.Set(reflect.Zero(self.objType))
+			return nil
+		}
+		if len(values) != sliceField.Len() {
+			return fmt.Errorf("%w: could not set value (%s) from %d elements", ErrInvalidFieldType, self.objType, len(values))
+		}
+	} else {
+		sliceField.Set(reflect.MakeSlice(self.objType, len(values), len(values)))
+	}
+	for index, readValue := range values {
+		if err := setFieldFromString(sliceField.Index(index), readValue); err != nil {
+			errs = append(errs, &FieldError{Field: strconv.Itoa(index), Value: readValue, Err: err})
+		}
+	}
+
+	if len(errs) != 0 {
+		return errs
+	}
+
+	return nil
+}
+
+func (self *reflectionData) encodeMap(encoder *Encoder, objValue reflect.Value) {
+	if self.plan != nil {
+		self.plan.EncodeMap(objValue.Addr().Interface(), encoder)
+		return
+	}
+
+	iter := objValue.Field(self.structIndex).MapRange()
+	for iter.Next() {
+		encoder.appendValue(iter.Key())
+		encoder.appendValue(iter.Value())
+	}
+}
+
+// decodeMap decodes the entries of a map field.
+// Every entry that fails to decode is reported in a MultiError of FieldErrors, identified by its key.
+func (self *reflectionData) decodeMap(objValue reflect.Value, values map[string]string) error {
+	if self.plan != nil {
+		return self.plan.DecodeMap(objValue.Addr().Interface(), values)
+	}
+
+	var errs MultiError
+
+	mapField := objValue.Field(self.structIndex)
+	mapField.Set(reflect.MakeMap(self.objType))
+
+	// SetMapIndex copies the key and value, so the same values are reused for every entry.
+	keyValue := reflect.New(self.objType.Key()).Elem()
+	valueValue := reflect.New(self.objType.Elem()).Elem()
+
+	for readKey, readValue := range values {
+		if err := setFieldFromString(keyValue, readKey); err != nil {
+			errs = append(errs, &FieldError{Field: readKey, Value: readKey, Err: err})
+			continue
+		}
+
+		if err := setFieldFromString(valueValue, readValue); err != nil {
+			errs = append(errs, &FieldError{Field: readKey, Value: readValue, Err: err})
+			continue
+		}
+
+		mapField.SetMapIndex(keyValue, valueValue)
+	}
+
+	if len(errs) != 0 {
+		return errs
+	}
+
+	return nil
+}
+
+// addressable returns an addressable copy of the struct value if it is not already addressable.
+// Generated plans operate on pointers to the struct.
+func addressable(objValue reflect.Value) reflect.Value {
+	if objValue.CanAddr() {
+		return objValue
+	}
+
+	addressableValue := reflect.New(objValue.Type()).Elem()
+	addressableValue.Set(objValue)
+
+	return addressableValue
+}
@@ -0,0 +1,450 @@
+package redisobj
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/go-redis/redis/v7"
+)
+
+type readStepKind int
+
+const (
+	readStepExists readStepKind = iota
+	readStepValues
+	readStepSlice
+	readStepMap
+	readStepFieldNames
+	readStepBlob
+)
+
+// readStep decodes the result of one pipelined read command into the object.
+type readStep struct {
+	kind         readStepKind
+	objStructRef *objStruct
+	data         *reflectionData
+	objValue     reflect.Value
+	// key is the redis key read by the command.
+	key *keyArg
+	// valuesStep is the index of the HMGET step holding the stored length of data, or -1. For slices, maps and blobs it
+	// is the HMGET of their struct, and for unkeyed nested structs the HMGET of the struct containing them.
+	valuesStep int
+}
+
+// readPlan holds one readStep per pipelined command, in command order.
+// Plans are pooled so that reads do not allocate a callback per command.
+type readPlan struct {
+	steps []readStep
+	// args backs the arguments of the pipelined commands, so that commands do not allocate an argument slice each.
+	args []interface{}
+	// keys backs the keys of the pipelined commands, and buf their bytes.
+	keys []keyArg
+	buf  []byte
+}
+
+// keyArg is a redis key passed to commands without boxing a string per key.
+// go-redis writes it as a binary value, and pointers to the keys of the plan convert to interfaces without allocating.
+type keyArg struct {
+	key []byte
+}
+
+func (self *keyArg) MarshalBinary() ([]byte, error) {
+	return self.key, nil
+}
+
+// String returns the key, such as when go-redis formats the command.
+func (self *keyArg) String() string {
+	return string(self.key)
+}
+
+var readPlanPool = sync.Pool{
+	New: func() interface{} {
+		return &readPlan{
+			steps: make([]readStep, 0, 16),
+			args:  make([]interface{}, 0, 64),
+			keys:  make([]keyArg, 0, 16),
+			buf:   make([]byte, 0, 512),
+		}
+	},
+}
+
+func getReadPlan() *readPlan {
+	return readPlanPool.Get().(*readPlan)
+}
+
+func putReadPlan(plan *readPlan) {
+	for index := range plan.steps {
+		// Do not hold on to the object after the read completes.
+		plan.steps[index] = readStep{}
+	}
+	plan.steps = plan.steps[:0]
+	for index := range plan.args {
+		plan.args[index] = nil
+	}
+	plan.args = plan.args[:0]
+	for index := range plan.keys {
+		plan.keys[index] = keyArg{}
+	}
+	plan.keys = plan.keys[:0]
+	plan.buf = plan.buf[:0]
+	readPlanPool.Put(plan)
+}
+
+// keyArgs returns the arguments of the command on the key, followed by args, backed by the plan.
+// Constant arguments such as the command name are boxed without allocating.
+func (self *readPlan) keyArgs(name string, key *keyArg, args ...interface{}) []interface{} {
+	count := 2 + len(args)
+	if len(self.args)+count > cap(self.args) {
+		// Commands queued before keep their arguments in the previous array.
+		self.args = make([]interface{}, 0, 2*cap(self.args)+count)
+	}
+
+	start := len(self.args)
+	self.args = append(self.args, name, key)
+	self.args = append(self.args, args...)
+
+	return self.args[start : start+count : start+count]
+}
+
+// key returns the key joined with the suffix, backed by the plan.
+func (self *readPlan) key(key string, suffix string) *keyArg {
+	if len(self.keys) == cap(self.keys) {
+		// Keys returned before keep pointing to the previous array.
+		self.keys = make([]keyArg, 0, 2*cap(self.keys))
+	}
+
+	// The key is capacity limited, so that later keys can never overwrite it, even when the buffer is reallocated.
+	start := len(self.buf)
+	self.buf = append(self.buf, key...)
+	self.buf = append(self.buf, suffix...)
+	end := len(self.buf)
+
+	self.keys = append(self.keys, keyArg{key: self.buf[start:end:end]})
+	return &self.keys[len(self.keys)-1]
+}
+
+// add appends a step and returns its index.
+func (self *readPlan) add(kind readStepKind, objStructRef *objStruct, data *reflectionData, objValue reflect.Value, key *keyArg, valuesStep int) int {
+	self.steps = append(self.steps, readStep{
+		kind:         kind,
+		objStructRef: objStructRef,
+		data:         data,
+		objValue:     objValue,
+		key:          key,
+		valuesStep:   valuesStep,
+	})
+
+	return len(self.steps) - 1
+}
+
+// applyResults decodes the results of the pipelined read commands into the object.
+// Results beyond the steps of the plan belong to other commands of the pipeline and are ignored.
+// Fields that fail to decode do not stop the remaining fields from being decoded; they are returned as a MultiError
+// of FieldErrors, or reported to Options.OnDecodeError with DecodeLenient.
+func (self *readPlan) applyResults(results []redis.Cmder, options Options) error {
+	var fieldErrs MultiError
+
+	for index, step := range self.steps {
+		if index >= len(results) {
+			return fmt.Errorf("%w: missing pipeline result", ErrRedisCommandError)
+		}
+
+		result := results[index]
+		if err := result.Err(); err != nil && err != redis.Nil {
+			return fmt.Errorf("%w: %s", ErrRedisCommandError, err)
+		}
+
+		if err := step.apply(result, results, options); err != nil {
+			if errs, ok := err.(MultiError); ok {
+				fieldErrs = append(fieldErrs, errs...)
+				continue
+			}
+			return err
+		}
+	}
+
+	if len(fieldErrs) == 0 {
+		return nil
+	}
+
+	if options.Decode == DecodeLenient {
+		if options.OnDecodeError != nil {
+			for _, err := range fieldErrs {
+				if fieldErr, ok := err.(*FieldError); ok {
+					options.OnDecodeError(fieldErr)
+				}
+			}
+		}
+		return nil
+	}
+
+	return fieldErrs
+}
+
+// fieldErrors completes the FieldErrors of a field that failed to decode with the location of the field.
+// Errors of generated plans are wrapped in a FieldError. The errors are returned as a MultiError.
+func (self *readStep) fieldErrors(err error, field *reflectionData, hashField string, value string) MultiError {
+	errs, ok := err.(MultiError)
+	if !ok {
+		errs = MultiError{&FieldError{Field: hashField, Value: value, Err: err}}
+	}
+
+	for _, err := range errs {
+		if fieldErr, ok := err.(*FieldError); ok {
+			fieldErr.Type = self.objStructRef.structData.objType
+			fieldErr.Path = field.path
+			fieldErr.Key = self.key.String()
+		}
+	}
+
+	return errs
+}
+
+func (self *readStep) apply(result redis.Cmder, results []redis.Cmder, options Options) error {
+	switch self.kind {
+	case readStepExists:
+		marker, err := result.(*redis.StringCmd).Result()
+		if err == redis.Nil {
+			if self.objStructRef.structData.structIndex != -1 {
+				// The keyed nested struct expired or was deleted while the object containing it exists.
+				return ErrPartialObject
+			}
+			return ErrObjectNotFound
+		}
+		if err != nil {
+			return fmt.Errorf("%w: %s", ErrRedisCommandError, err)
+		}
+		if marker == existenceMarkerMissing {
+			return ErrObjectKnownMissing
+		}
+
+	case readStepValues:
+		reply, err := result.(*redis.Cmd).Result()
+		if err != nil {
+			return fmt.Errorf("%w: %s", ErrRedisCommandError, err)
+		}
+		redisValues, _ := reply.([]interface{})
+		if len(redisValues) != len(self.objStructRef.readFieldArgs) {
+			return fmt.Errorf("%w: unexpected HMGET reply (%v)", ErrRedisCommandError, reply)
+		}
+
+		// Value fields are always written together, so the hash is missing if none of its fields are present.
+		// Fields added to the struct since the object was written are missing on their own, and read as zero values.
+		if len(self.objStructRef.valueFields) != 0 && !anyPresent(redisValues) {
+			// Unkeyed nested structs are only known to be stored if the struct containing them holds their field count.
+			// Otherwise the nested struct was added since the object was written.
+			if _, exists, _ := self.storedLength(results); self.objStructRef.isCacheable() || exists {
+				return ErrPartialObject
+			}
+		}
+
+		var errs MultiError
+		for index, valueField := range self.objStructRef.valueFields {
+			redisValue, exists := redisValues[index].(string)
+			if !exists {
+				// Return a "not found" error if this was a key.
+				if valueField.isKey {
+					return ErrObjectNotFound
+				}
+
+				if options.Decode == DecodeStrict {
+					errs = append(errs, self.fieldErrors(ErrMissingField, valueField, valueField.objName, "")...)
+					continue
+				}
+			}
+
+			if err := valueField.decodeValue(self.objValue, redisValue); err != nil {
+				errs = append(errs, self.fieldErrors(err, valueField, valueField.objName, redisValue)...)
+			}
+		}
+
+		if len(errs) != 0 {
+			return errs
+		}
+
+	case readStepSlice:
+		redisValue, err := result.(*redis.StringSliceCmd).Result()
+		if err != nil {
+			if err == redis.Nil {
+				redisValue = nil
+			} else {
+				return fmt.Errorf("%w Get: %s", ErrRedisCommandError, err)
+			}
+		}
+
+		if decoded, err := self.checkCollection(len(redisValue), results); decoded || err != nil {
+			return err
+		}
+
+		if err := self.data.decodeSlice(self.objValue, redisValue); err != nil {
+			return self.fieldErrors(err, self.data, "", "")
+		}
+
+	case readStepMap:
+		redisValue, err := result.(*redis.StringStringMapCmd).Result()
+		if err != nil {
+			if err == redis.Nil {
+				redisValue = nil
+			} else {
+				return fmt.Errorf("%w Get: %s", ErrRedisCommandError, err)
+			}
+		}
+
+		if decoded, err := self.checkCollection(len(redisValue), results); decoded || err != nil {
+			return err
+		}
+
+		if err := self.data.decodeMap(self.objValue, redisValue); err != nil {
+			return self.fieldErrors(err, self.data, "", "")
+		}
+
+	case readStepBlob:
+		redisValue, err := result.(*redis.StringCmd).Result()
+		if err != nil && err != redis.Nil {
+			return fmt.Errorf("%w Get: %s", ErrRedisCommandError, err)
+		}
+
+		if decoded, err := self.checkCollection(len(redisValue), results); decoded || err != nil {
+			return err
+		}
+
+		if err := self.data.decodeValue(self.objValue, redisValue); err != nil {
+			return self.fieldErrors(err, self.data, "", redisValue)
+		}
+
+		// Empty values decode to nil, while stored blobs are only nil without a stored length.
+		if field := self.objValue.Field(self.data.structIndex); redisValue == "" && field.Kind() == reflect.Slice {
+			if _, exists, _ := self.storedLength(results); exists {
+				field.Set(reflect.MakeSlice(field.Type(), 0, 0))
+			}
+		}
+
+	case readStepFieldNames:
+		fieldNames, err := result.(*redis.StringSliceCmd).Result()
+		if err != nil {
+			return fmt.Errorf("%w: %s", ErrRedisCommandError, err)
+		}
+
+		var errs MultiError
+		for _, fieldName := range fieldNames {
+			if !self.objStructRef.hasHashField(fieldName) {
+				errs = append(errs, &FieldError{
+					Type:  self.objStructRef.structData.objType,
+					Path:  self.objStructRef.structData.path + "." + fieldName,
+					Key:   self.key.String(),
+					Field: fieldName,
+					Err:   ErrUnknownField,
+				})
+			}
+		}
+
+		if len(errs) != 0 {
+			return errs
+		}
+	}
+
+	return nil
+}
+
+// hasHashField reports if the field of the struct hash belongs to the struct.
+// Fields starting with "__" hold metadata, such as the lengths of slices and maps.
+func (self *objStruct) hasHashField(fieldName string) bool {
+	if strings.HasPrefix(fieldName, "__") {
+		return true
+	}
+
+	for _, valueField := range self.valueFields {
+		if valueField.objName == fieldName {
+			return true
+		}
+	}
+
+	return false
+}
+
+// anyPresent reports if any of the HMGET values is present in redis.
+func anyPresent(redisValues []interface{}) bool {
+	for _, redisValue := range redisValues {
+		if redisValue != nil {
+			return true
+		}
+	}
+
+	return false
+}
+
+// storedLength returns the length of a slice, map or blob field, or the field count of an unkeyed nested struct, read
+// with the hash of the struct containing it.
+// exists is false for nil fields. tracked is false for fields without a stored length, and for every field of objects
+// written before lengths were stored, which are read without length checks.
+func (self *readStep) storedLength(results []redis.Cmder) (length int, exists bool, tracked bool) {
+	if self.valuesStep == -1 || self.data.lengthIndex == -1 {
+		return 0, false, false
+	}
+
+	redisValues, _ := results[self.valuesStep].(*redis.Cmd).Val().([]interface{})
+	if self.data.lengthIndex >= len(redisValues) {
+		return 0, false, false
+	}
+
+	lengths, tracked := redisValues[self.data.lengthIndex].(string)
+	if !tracked {
+		return 0, false, false
+	}
+
+	length, exists = parseLength(lengths, self.data.lengthName)
+	return length, exists, true
+}
+
+// parseLength returns the length stored under name in the value of a length field, and if it is present.
+// Malformed lengths are returned as -1, so that they match no length read.
+func parseLength(lengths string, name string) (length int, exists bool) {
+	for lengths != "" {
+		entry := lengths
+		if index := strings.IndexByte(lengths, ','); index != -1 {
+			entry, lengths = lengths[:index], lengths[index+1:]
+		} else {
+			lengths = ""
+		}
+
+		if len(entry) <= len(name) || entry[len(name)] != '=' || entry[:len(name)] != name {
+			continue
+		}
+
+		length, err := strconv.Atoi(entry[len(name)+1:])
+		if err != nil {
+			return -1, true
+		}
+		return length, true
+	}
+
+	return 0, false
+}
+
+// checkCollection compares the length of a slice or map read from redis with its stored length.
+// Fields stored as nil are set to nil, and reported as decoded.
+func (self *readStep) checkCollection(length int, results []redis.Cmder) (decoded bool, err error) {
+	expectedLength, exists, tracked := self.storedLength(results)
+	if !tracked {
+		return false, nil
+	}
+
+	if !exists {
+		if length != 0 {
+			return false, ErrPartialObject
+		}
+
+		field := self.objValue.Field(self.data.structIndex)
+		field.Set(reflect.Zero(field.Type()))
+		return true, nil
+	}
+
+	if expectedLength != length {
+		return false, ErrPartialObject
+	}
+
+	return false, nil
+}
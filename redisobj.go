@@ -2,6 +2,7 @@ package redisobj
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"reflect"
 	"sync"
@@ -11,7 +12,10 @@ import (
 )
 
 const (
-	rootKeyPrefix = "redisobj"
+	defaultNamespace = "redisobj"
+	// namespaceSeparator joins the namespaces of child stores. Type names and escaped key values are never empty, so
+	// no key of a parent namespace contains an empty segment and collides with the keys of its children.
+	namespaceSeparator = "::"
 )
 
 type Writer interface {
@@ -24,20 +28,195 @@ type Reader interface {
 
 type Store struct {
 	redisClient *redis.Client // FIXME: This is forced to be either Client or ClusterClient which is really annoying.
-	mutex       *sync.RWMutex
-	objTypes    map[string]*objStruct // FIXME: Need to sync this map
+	types       *typeRegistry
+	namespace   string
+	localCache  *localCache
+	loads       *loadGroup
+	persisters  *persisterRegistry
+	loaders     *loaderRegistry
+	// refreshes holds the keys of the objects being refreshed in the background.
+	refreshes *sync.Map
+	// trackInvalidations makes the local cache rely on CLIENT TRACKING rather than pub/sub.
+	trackInvalidations bool
 }
 
-func NewStore(redisClient *redis.Client) *Store {
-	return &Store{
+// StoreOption configures a Store created with NewStore.
+type StoreOption func(store *Store)
+
+// Namespace sets the key prefix of every object written by the Store. Defaults to "redisobj".
+// Reserved key characters in the namespace are escaped the same way as key values.
+func Namespace(namespace string) StoreOption {
+	return func(store *Store) {
+		if namespace != "" {
+			store.namespace = escapeKeyValue(namespace)
+		}
+	}
+}
+
+// RequireRegistration refuses to lazily register types on first use.
+// Every type must be registered with Register, MustRegister, or RegisterType before it is written or read.
+func RequireRegistration() StoreOption {
+	return func(store *Store) {
+		store.types.lazy = false
+	}
+}
+
+// LocalCache keeps up to size objects read by the Store in process, each for at most ttl. A ttl of 0 keeps objects until evicted.
+// Writes and deletes publish the changed object keys on a redis channel, and every Store with a local cache evicts them.
+// Stores writing the cached objects must enable the local cache as well, otherwise their changes are not announced.
+// Close the Store to stop listening for invalidations.
+func LocalCache(size int, ttl time.Duration) StoreOption {
+	return func(store *Store) {
+		if size > 0 {
+			store.localCache = newLocalCache(size, ttl)
+		}
+	}
+}
+
+// LocalCacheTracking makes the local cache rely on redis server-assisted client side caching instead of pub/sub.
+// Redis 6 or newer broadcasts the invalidation of every key in the Store namespace, including the keys of map and
+// slice fields, whichever client changed them. Requires LocalCache.
+func LocalCacheTracking() StoreOption {
+	return func(store *Store) {
+		store.trackInvalidations = true
+	}
+}
+
+func NewStore(redisClient *redis.Client, options ...StoreOption) *Store {
+	store := &Store{
 		redisClient: redisClient,
-		mutex:       &sync.RWMutex{},
-		objTypes:    map[string]*objStruct{},
+		types:       newTypeRegistry(),
+		namespace:   defaultNamespace,
+		loads:       newLoadGroup(),
+		persisters:  &persisterRegistry{},
+		loaders:     &loaderRegistry{},
+		refreshes:   &sync.Map{},
 	}
+
+	for _, option := range options {
+		option(store)
+	}
+
+	if store.localCache != nil {
+		if store.trackInvalidations {
+			store.localCache.track(redisClient, "{"+store.namespace+":")
+		} else {
+			store.localCache.subscribe(redisClient, store.namespace+localCacheChannelSuffix)
+		}
+	}
+
+	return store
+}
+
+// Close flushes the write-behind queues of the attached Persisters and stops the invalidation subscription of the
+// local cache. Child stores share both, so they must not be used after their parent is closed.
+func (self *Store) Close() error {
+	errs := MultiError{}
+
+	self.persisters.persisters.Range(func(_, value interface{}) bool {
+		if err := value.(*persistence).close(); err != nil {
+			errs = append(errs, err)
+		}
+		return true
+	})
+
+	if self.localCache != nil {
+		if err := self.localCache.close(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	if len(errs) != 0 {
+		return errs
+	}
+
+	return nil
+}
+
+// WithNamespace creates a child Store that writes under the namespace nested in this Store's namespace.
+// For example, a default Store's child "tenantA" writes keys such as {redisobj::tenantA:Item:123}.
+// The child shares the redis client, the type cache, the local cache, and the attached Persisters and Loaders with
+// this Store.
+func (self *Store) WithNamespace(namespace string) *Store {
+	if namespace == "" {
+		namespace = emptyKeyValue
+	} else {
+		namespace = escapeKeyValue(namespace)
+	}
+
+	return &Store{
+		redisClient: self.redisClient,
+		types:       self.types,
+		namespace:   self.namespace + namespaceSeparator + namespace,
+		localCache:  self.localCache,
+		loads:       self.loads,
+		persisters:  self.persisters,
+		loaders:     self.loaders,
+		refreshes:   self.refreshes,
+	}
+}
+
+// Register validates and caches the struct layouts of the given objects.
+// Registering types at startup reports every invalid field up front instead of on first use.
+// The returned error is a MultiError containing an error for every invalid field of every type.
+func (self *Store) Register(objs ...interface{}) error {
+	errs := MultiError{}
+
+	for _, obj := range objs {
+		objType, err := structType(obj)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+
+		if _, err := self.types.register(objType, ""); err != nil {
+			if multiErr, ok := err.(MultiError); ok {
+				errs = append(errs, multiErr...)
+			} else {
+				errs = append(errs, err)
+			}
+		}
+	}
+
+	if len(errs) != 0 {
+		return errs
+	}
+
+	return nil
+}
+
+// MustRegister is like Register but panics if any type is invalid.
+func (self *Store) MustRegister(objs ...interface{}) {
+	if err := self.Register(objs...); err != nil {
+		panic(err)
+	}
+}
+
+// RegisterType stores objects of the struct type under the given type name instead of the Go type name.
+// This keeps keys stable across package moves and renames and resolves conflicts between types with the same name.
+func (self *Store) RegisterType(obj interface{}, name string) error {
+	objType, err := structType(obj)
+	if err != nil {
+		return err
+	}
+
+	_, err = self.types.register(objType, name)
+	return err
+}
+
+func structType(obj interface{}) (reflect.Type, error) {
+	objType := reflect.TypeOf(obj)
+	if objType != nil && objType.Kind() == reflect.Ptr {
+		objType = objType.Elem()
+	}
+	if objType == nil || objType.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("%w: object must be a struct", ErrInvalidObject)
+	}
+
+	return objType, nil
 }
 
 func (self *Store) getObjectStruct(obj interface{}) (*objStruct, reflect.Value, error) {
-	var err error
 	objValue := reflect.ValueOf(obj)
 	if objValue.Kind() == reflect.Ptr {
 		if objValue.IsNil() {
@@ -47,28 +226,78 @@ func (self *Store) getObjectStruct(obj interface{}) (*objStruct, reflect.Value,
 		objValue = objValue.Elem()
 	}
 
-	self.mutex.RLock()
-	objStructRef, exists := self.objTypes[objValue.Type().Name()]
-	self.mutex.RUnlock()
-
-	if !exists {
-		// Lazy initialize struct definitions.
-		objStructRef, err = newObjStruct(obj)
-		if err != nil {
-			return objStructRef, objValue, err
-		}
+	if objValue.Kind() != reflect.Struct {
+		return nil, objValue, fmt.Errorf("%w: object must be a struct", ErrInvalidObject)
+	}
 
-		self.mutex.Lock()
-		self.objTypes[objValue.Type().Name()] = objStructRef
-		self.mutex.Unlock()
+	// Lazy initialize struct definitions.
+	objStructRef, err := self.types.get(objValue.Type())
+	if err != nil {
+		return nil, objValue, err
 	}
 
 	return objStructRef, objValue, nil
 }
 
+// DecodeMode selects how reads handle data in redis that does not match the struct.
+type DecodeMode int
+
+const (
+	// DecodeDefault fails reads on values that cannot be parsed, and reads missing fields as zero values.
+	DecodeDefault DecodeMode = iota
+	// DecodeStrict also fails reads on fields missing in redis, and on hash fields the struct does not have.
+	DecodeStrict
+	// DecodeLenient skips values that cannot be parsed, reporting them to Options.OnDecodeError, and reads missing
+	// fields as zero values.
+	DecodeLenient
+)
+
 type Options struct {
 	EnableCaching bool
 	Ttl           time.Duration
+	// StrictKeys rejects empty key values and key values containing ':', '{', '}' or '%' with ErrInvalidKey.
+	// Otherwise reserved characters are percent encoded and empty key values are stored under "%00".
+	StrictKeys bool
+	// MissingTtl makes ReadOrLoad record objects its loader does not find as missing for this long.
+	// Until the record expires or the object is written, reads return ErrObjectKnownMissing and the loader is not called.
+	MissingTtl time.Duration
+	// EarlyRefresh makes reads of objects close to expiry occasionally reload them in the background with the Loader
+	// registered for their type, before they expire (XFetch). The refreshed object is written with these options.
+	// The value scales how early objects are refreshed; 1 is a good default. 0 disables early refresh.
+	EarlyRefresh float64
+	// SlidingTtl makes reads extend the expiry of every key of existing objects to this duration, in the read pipeline.
+	SlidingTtl time.Duration
+	// ExpireAt makes writes expire every key of the object at this time. It takes precedence over Ttl.
+	ExpireAt time.Time
+	// Decode selects how reads handle data that does not match the struct. Failures are reported as FieldErrors.
+	Decode DecodeMode
+	// OnDecodeError is called with every field skipped by DecodeLenient.
+	OnDecodeError func(err *FieldError)
+	// KeepTtl makes writes keep the expiry the object already has, instead of changing it. Objects written for the
+	// first time do not expire. It takes precedence over ExpireAt and Ttl.
+	KeepTtl bool
+}
+
+// expire queues the expiry of a key written with the options to the pipeline.
+func (self Options) expire(pipe redis.Pipeliner, key string) {
+	switch {
+	case self.KeepTtl:
+		// The expiry is restored by writeToRedis once all keys of the struct are written.
+	case !self.ExpireAt.IsZero():
+		pipe.PExpireAt(key, self.ExpireAt)
+	case self.Ttl != 0:
+		pipe.PExpire(key, self.Ttl)
+	}
+}
+
+// client returns the redis client bound to the context.
+// Binding a context copies the client, so the copy is skipped if the client is already bound to it.
+func (self *Store) client(ctx context.Context) *redis.Client {
+	if ctx == self.redisClient.Context() {
+		return self.redisClient
+	}
+
+	return self.redisClient.WithContext(ctx)
 }
 
 func (self *Store) Write(ctx context.Context, obj interface{}, options Options) error {
@@ -77,17 +306,74 @@ func (self *Store) Write(ctx context.Context, obj interface{}, options Options)
 		return err
 	}
 
+	if objStructRef.hasPlans {
+		objValue = addressable(objValue)
+	}
+
+	encoder := getEncoder()
+	defer putEncoder(encoder)
+
+	redisClient := self.client(ctx)
+
 	// FIXME: This should be TxPipeline but there is a bug in go-redis/v7
 	//        See: https://github.com/go-redis/redis/pull/1823
-	pipe := self.redisClient.WithContext(ctx).Pipeline()
+	pipe := redisClient.Pipeline()
+
+	var cache cacheChecks
+	if options.EnableCaching {
+		if cache, err = objStructRef.checkCache(redisClient, self.namespace, objValue, true, options); err != nil {
+			return err
+		}
+	}
+
+	persistenceRef := self.persisters.get(objValue.Type())
+	if persistenceRef != nil {
+		key, err := objStructRef.key(self.namespace, objValue, options)
+		if err != nil {
+			return err
+		}
+
+		// Unchanged objects are not persisted again.
+		if cache.isFresh(key) {
+			persistenceRef = nil
+		} else if err := persistenceRef.writeThrough(obj); err != nil {
+			cache.invalidate(redisClient)
+			return err
+		}
+	}
 
-	if err = objStructRef.writeToRedis(ctx, self.redisClient, pipe, rootKeyPrefix, objValue, options); err != nil {
+	if err = objStructRef.writeToRedis(pipe, cache, encoder, self.namespace, objValue, options); err != nil {
 		return err
 	}
 
+	if err := persistenceRef.enqueue(pipe, objValue); err != nil {
+		cache.invalidate(redisClient)
+		return err
+	}
+
+	var changedKeys []string
+	if self.localCache != nil {
+		objectKeys, err := objStructRef.objectKeys(self.namespace, objValue, options, nil)
+		if err != nil {
+			return err
+		}
+
+		for _, key := range objectKeys {
+			if !cache.isFresh(key) {
+				changedKeys = append(changedKeys, key)
+			}
+		}
+
+		// Invalidations are published after the data commands in the same pipeline.
+		self.localCache.publish(pipe, changedKeys)
+		defer self.localCache.invalidate(changedKeys...)
+	}
+
 	results, _ := pipe.Exec()
 	for _, result := range results {
 		if err := result.Err(); err != nil && err != redis.Nil {
+			// The new hashes were stored with the cache checks, so drop them to avoid skipping the next write.
+			cache.invalidate(redisClient)
 			return fmt.Errorf("%w: %s", ErrRedisCommandError, err)
 		}
 	}
@@ -95,34 +381,341 @@ func (self *Store) Write(ctx context.Context, obj interface{}, options Options)
 	return nil
 }
 
-type readResultsCallback func(result redis.Cmder) error
-
 func (self *Store) Read(ctx context.Context, obj interface{}, options Options) error {
 	objStructRef, objValue, err := self.getObjectStruct(obj)
 	if err != nil {
 		return err
 	}
 
+	var key string
+	var objectKeys []string
+	var generation uint64
+	if self.localCache != nil {
+		// Keyed nested structs are located by the target object, so entries only match reads of the same object keys.
+		if objectKeys, err = objStructRef.objectKeys(self.namespace, objValue, options, nil); err != nil {
+			return err
+		}
+		key = objectKeys[0]
+		if self.localCache.get(objStructRef, objectKeys, objValue) {
+			if options.SlidingTtl > 0 || options.EarlyRefresh > 0 {
+				return self.touch(ctx, objStructRef, objValue, key, options)
+			}
+			return nil
+		}
+
+		generation = self.localCache.currentGeneration()
+	}
+
+	plan := getReadPlan()
+	defer putReadPlan(plan)
+
+	redisClient := self.client(ctx)
+
 	// FIXME: This should be TxPipeline but there is a bug in go-redis/v7
 	//        See: https://github.com/go-redis/redis/pull/1823
-	pipe := self.redisClient.WithContext(ctx).Pipeline()
+	pipe := redisClient.Pipeline()
+
+	var cache cacheChecks
+	if options.EnableCaching {
+		if cache, err = objStructRef.checkCache(redisClient, self.namespace, objValue, false, options); err != nil {
+			return err
+		}
+	}
 
-	callbacks := []readResultsCallback{}
-	if err := objStructRef.readFromRedis(ctx, self.redisClient, pipe, &callbacks, rootKeyPrefix, objValue, options); err != nil {
+	if err := objStructRef.readFromRedis(pipe, cache, plan, self.namespace, objValue, -1, options); err != nil {
 		return err
 	}
 
+	if options.SlidingTtl > 0 {
+		if err := objStructRef.slideTtl(pipe, self.namespace, objValue, options.SlidingTtl, options); err != nil {
+			return err
+		}
+	}
+
+	// Objects expiring in redis publish no invalidation, so cached entries must not outlive any of the object keys, nor
+	// any of the fields with a ttl tag.
+	var ttlResults []*redis.DurationCmd
+	for _, objectKey := range objectKeys {
+		ttlResults = append(ttlResults, pipe.PTTL(objectKey+".__EXISTS__"))
+	}
+	if self.localCache != nil {
+		fieldTtlKeys, err := objStructRef.appendFieldTtlKeys(self.namespace, objValue, options, nil)
+		if err != nil {
+			return err
+		}
+		for _, fieldTtlKey := range fieldTtlKeys {
+			ttlResults = append(ttlResults, pipe.PTTL(fieldTtlKey))
+		}
+	}
+
+	var refreshLoader *registeredLoader
+	var ttlResult *redis.DurationCmd
+	if options.EarlyRefresh > 0 {
+		if refreshLoader = self.loaders.get(objValue.Type()); refreshLoader != nil {
+			if len(ttlResults) != 0 {
+				ttlResult = ttlResults[0]
+			} else {
+				if key, err = objStructRef.key(self.namespace, objValue, options); err != nil {
+					return err
+				}
+
+				ttlResult = pipe.PTTL(key + ".__EXISTS__")
+			}
+		}
+	}
+
 	results, _ := pipe.Exec()
 
-	for index, result := range results {
-		if err := result.Err(); err != nil && err != redis.Nil {
-			return fmt.Errorf("%w: %s", ErrRedisCommandError, err)
+	if err := plan.applyResults(results, options); err != nil {
+		return err
+	}
+
+	if ttlResult != nil {
+		if ttl, err := ttlResult.Result(); err == nil && ttl > 0 && refreshLoader.shouldRefresh(ttl, options.EarlyRefresh) {
+			self.refresh(objStructRef, objValue, key, refreshLoader, options)
 		}
+	}
+
+	if self.localCache != nil {
+		self.localCache.put(objStructRef, objValue, objectKeys, shortestTtl(ttlResults), generation)
+	}
+
+	return nil
+}
+
+// touch slides the expiry of an object served from the local cache and checks it for early refresh, without reading
+// its data again.
+func (self *Store) touch(ctx context.Context, objStructRef *objStruct, objValue reflect.Value, key string, options Options) error {
+	pipe := self.client(ctx).Pipeline()
+
+	if options.SlidingTtl > 0 {
+		if err := objStructRef.slideTtl(pipe, self.namespace, objValue, options.SlidingTtl, options); err != nil {
+			return err
+		}
+	}
+
+	var refreshLoader *registeredLoader
+	var ttlResult *redis.DurationCmd
+	if options.EarlyRefresh > 0 {
+		if refreshLoader = self.loaders.get(objValue.Type()); refreshLoader != nil {
+			ttlResult = pipe.PTTL(key + ".__EXISTS__")
+		}
+	}
+
+	// Like reads from redis, failing to slide the expiry does not fail the read.
+	_, _ = pipe.Exec()
+
+	if ttlResult != nil {
+		if ttl, err := ttlResult.Result(); err == nil && ttl > 0 && refreshLoader.shouldRefresh(ttl, options.EarlyRefresh) {
+			self.refresh(objStructRef, objValue, key, refreshLoader, options)
+		}
+	}
+
+	return nil
+}
+
+// ReadOrLoad reads the object, or loads it with the loader and writes it with the options if it does not exist.
+// If the loader is nil, the Loader registered for the type is used.
+// Objects recorded as missing with Options.MissingTtl are not loaded and return ErrObjectKnownMissing.
+// Concurrent misses of the same object are coalesced, so only one of them calls the loader and the others receive a
+// copy of the loaded object. If writing the loaded object fails, obj holds the loaded object and the error is returned.
+func (self *Store) ReadOrLoad(ctx context.Context, obj interface{}, loader Loader, options Options) error {
+	err := self.Read(ctx, obj, options)
+	if !errors.Is(err, ErrObjectNotFound) || errors.Is(err, ErrObjectKnownMissing) {
+		return err
+	}
+
+	objStructRef, objValue, err := self.getObjectStruct(obj)
+	if err != nil {
+		return err
+	}
+
+	key, err := objStructRef.key(self.namespace, objValue, options)
+	if err != nil {
+		return err
+	}
+
+	if loader == nil {
+		registered := self.loaders.get(objValue.Type())
+		if registered == nil {
+			return fmt.Errorf("%w: %s", ErrLoaderNotRegistered, objValue.Type())
+		}
+		loader = registered
+	}
+
+	return self.load(ctx, objStructRef, objValue, obj, key, loader, options)
+}
+
+// load loads the object with the loader and writes it, coalescing concurrent loads of the key.
+func (self *Store) load(ctx context.Context, objStructRef *objStruct, objValue reflect.Value, obj interface{}, key string, loader Loader, options Options) error {
+	return self.loads.do(key, objStructRef, objValue, func() error {
+		if err := loader.Load(ctx, obj); err != nil {
+			if errors.Is(err, ErrObjectNotFound) && options.MissingTtl > 0 {
+				if err := self.writeMissing(ctx, objStructRef, key, options.MissingTtl); err != nil {
+					return err
+				}
+			}
+
+			return err
+		}
+
+		return self.Write(ctx, obj, options)
+	})
+}
+
+// refresh reloads the object in the background, unless it is already being refreshed.
+// The object is copied first, so that the loader starts from the object as read, including its key fields.
+func (self *Store) refresh(objStructRef *objStruct, objValue reflect.Value, key string, loader Loader, options Options) {
+	if _, refreshing := self.refreshes.LoadOrStore(key, struct{}{}); refreshing {
+		return
+	}
+
+	refreshValue := reflect.New(objValue.Type())
+	objStructRef.copyObject(refreshValue.Elem(), objValue)
+
+	go func() {
+		defer self.refreshes.Delete(key)
+
+		// The refresh outlives the read, so it is not bound to the context of the read.
+		_ = self.load(context.Background(), objStructRef, refreshValue.Elem(), refreshValue.Interface(), key, loader, options)
+	}()
+}
+
+// RegisterLoader registers the loader for the type of obj.
+// The loader is used by ReadOrLoad when called without a loader, and to refresh objects early with Options.EarlyRefresh.
+func (self *Store) RegisterLoader(obj interface{}, loader Loader) error {
+	objType, err := structType(obj)
+	if err != nil {
+		return err
+	}
 
-		if err := callbacks[index](result); err != nil {
+	if _, err := self.types.get(objType); err != nil {
+		return err
+	}
+
+	self.loaders.loaders.Store(objType, &registeredLoader{
+		loader: loader,
+	})
+
+	return nil
+}
+
+// writeMissing records the object stored under the key as missing for the ttl.
+func (self *Store) writeMissing(ctx context.Context, objStructRef *objStruct, key string, ttl time.Duration) error {
+	pipe := self.client(ctx).Pipeline()
+
+	objStructRef.writeMissing(pipe, key, ttl)
+	if self.localCache != nil {
+		self.localCache.publish(pipe, []string{key})
+		defer self.localCache.invalidate(key)
+	}
+
+	if _, err := pipe.Exec(); err != nil {
+		return fmt.Errorf("%w: %s", ErrRedisCommandError, err)
+	}
+
+	return nil
+}
+
+// Delete removes the object from redis. Keyed nested structs are objects of their own and are not deleted.
+// Deleting an object that does not exist is not an error.
+func (self *Store) Delete(ctx context.Context, obj interface{}, options Options) error {
+	objStructRef, objValue, err := self.getObjectStruct(obj)
+	if err != nil {
+		return err
+	}
+
+	redisClient := self.client(ctx)
+
+	// FIXME: This should be TxPipeline but there is a bug in go-redis/v7
+	//        See: https://github.com/go-redis/redis/pull/1823
+	pipe := redisClient.Pipeline()
+
+	if err := objStructRef.deleteFromRedis(pipe, self.namespace, objValue, options); err != nil {
+		return err
+	}
+
+	if self.localCache != nil {
+		key, err := objStructRef.key(self.namespace, objValue, options)
+		if err != nil {
 			return err
 		}
+
+		self.localCache.publish(pipe, []string{key})
+		defer self.localCache.invalidate(key)
+	}
+
+	results, _ := pipe.Exec()
+	for _, result := range results {
+		if err := result.Err(); err != nil {
+			return fmt.Errorf("%w: %s", ErrRedisCommandError, err)
+		}
 	}
 
 	return nil
 }
+
+// ReadIfChanged reads the object unless etag matches the hashes stored for it, in which case ErrNotModified is returned
+// without transferring any fields.
+// The returned etag combines the hashes stored for the root object and every keyed nested struct alongside the data that was read,
+// so writing a keyed nested struct on its own also changes the etag.
+// Hashes are only stored by writes with EnableCaching, so for other objects the etag is empty and the object is always read.
+func (self *Store) ReadIfChanged(ctx context.Context, obj interface{}, etag string, options Options) (string, error) {
+	objStructRef, objValue, err := self.getObjectStruct(obj)
+	if err != nil {
+		return "", err
+	}
+
+	// Every cacheable struct of the object stores its hash next to its data.
+	objectKeys, err := objStructRef.objectKeys(self.namespace, objValue, options, nil)
+	if err != nil {
+		return "", err
+	}
+	hashResults := make([]*redis.StringCmd, len(objectKeys))
+
+	redisClient := self.client(ctx)
+
+	if etag != "" {
+		// Keyed structs live in different hash slots, so each hash is read on its own.
+		pipe := redisClient.Pipeline()
+		for index, objectKey := range objectKeys {
+			hashResults[index] = pipe.Get(objectKey + ".__HASH__")
+		}
+		if _, err := pipe.Exec(); err != nil && err != redis.Nil {
+			return "", fmt.Errorf("%w: %s", ErrCacheFailure, err)
+		}
+
+		storedEtag, err := combineEtag(hashResults)
+		if err != nil {
+			return "", err
+		}
+		if storedEtag == etag {
+			return etag, ErrNotModified
+		}
+	}
+
+	plan := getReadPlan()
+	defer putReadPlan(plan)
+
+	// FIXME: This should be TxPipeline but there is a bug in go-redis/v7
+	//        See: https://github.com/go-redis/redis/pull/1823
+	pipe := redisClient.Pipeline()
+
+	// The caller asked for the stored object, so the hash of the target object is not checked.
+	if err := objStructRef.readFromRedis(pipe, nil, plan, self.namespace, objValue, -1, options); err != nil {
+		return "", err
+	}
+
+	// The hashes are read in the same pipeline so that they match the data read.
+	for index, objectKey := range objectKeys {
+		hashResults[index] = pipe.Get(objectKey + ".__HASH__")
+	}
+
+	results, _ := pipe.Exec()
+
+	if err := plan.applyResults(results[:len(results)-len(objectKeys)], options); err != nil {
+		return "", err
+	}
+
+	return combineEtag(hashResults)
+}
@@ -2,7 +2,12 @@ package redisobj_test
 
 import (
 	"context"
+	"errors"
+	"math"
 	"redisobj"
+	"reflect"
+	"strconv"
+	"sync"
 	"testing"
 	"time"
 
@@ -422,3 +427,2247 @@ func Test_Store_keyed_object(t *testing.T) {
 		})
 	}
 }
+
+func Test_Store_key_values(t *testing.T) {
+	redisClient := NewGoRedisClient()
+	redisClient.FlushAll()
+	ctx := context.Background()
+
+	type root struct {
+		Id     string `redisobj:"key"`
+		String string
+	}
+
+	objStore := redisobj.NewStore(redisClient)
+
+	testCases := []struct {
+		description   string
+		id            string
+		options       redisobj.Options
+		expectedKey   string
+		expectedError error
+	}{
+		{
+			description:   "plain key value",
+			id:            "UUID",
+			options:       redisobj.Options{},
+			expectedKey:   "{redisobj:root:UUID}",
+			expectedError: nil,
+		},
+		{
+			description:   "reserved characters are escaped",
+			id:            "a:b{c}d%e",
+			options:       redisobj.Options{},
+			expectedKey:   "{redisobj:root:a%3Ab%7Bc%7Dd%25e}",
+			expectedError: nil,
+		},
+		{
+			description:   "empty key value",
+			id:            "",
+			options:       redisobj.Options{},
+			expectedKey:   "{redisobj:root:%00}",
+			expectedError: nil,
+		},
+		{
+			description:   "key value of an escape sequence",
+			id:            "%00",
+			options:       redisobj.Options{},
+			expectedKey:   "{redisobj:root:%2500}",
+			expectedError: nil,
+		},
+		{
+			description: "strict - empty key value",
+			id:          "",
+			options: redisobj.Options{
+				StrictKeys: true,
+			},
+			expectedError: redisobj.ErrInvalidKey,
+		},
+		{
+			description: "strict - reserved characters",
+			id:          "a}b",
+			options: redisobj.Options{
+				StrictKeys: true,
+			},
+			expectedError: redisobj.ErrInvalidKey,
+		},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.description, func(t *testing.T) {
+			object := &root{
+				Id:     testCase.id,
+				String: "root_string",
+			}
+
+			err := objStore.Write(ctx, object, testCase.options)
+			assert.ErrorIs(t, err, testCase.expectedError)
+
+			actualObject := &root{
+				Id: testCase.id,
+			}
+			err = objStore.Read(ctx, actualObject, testCase.options)
+			assert.ErrorIs(t, err, testCase.expectedError)
+
+			if testCase.expectedError == nil {
+				assert.Equal(t, object, actualObject)
+
+				exists, err := redisClient.Exists(testCase.expectedKey).Result()
+				assert.Nil(t, err)
+				assert.Equal(t, int64(1), exists)
+			}
+		})
+	}
+
+	// The empty key value does not share its key with any other key value.
+	redisClient.FlushAll()
+	err := objStore.Write(ctx, &root{Id: ""}, redisobj.Options{})
+	assert.Nil(t, err)
+
+	for _, id := range []string{"none", "%00", "\x00"} {
+		err = objStore.Read(ctx, &root{Id: id}, redisobj.Options{})
+		assert.ErrorIs(t, err, redisobj.ErrObjectNotFound)
+	}
+}
+
+func Test_Store_namespace(t *testing.T) {
+	redisClient := NewGoRedisClient()
+	redisClient.FlushAll()
+	ctx := context.Background()
+
+	type root struct {
+		Id     string `redisobj:"key"`
+		String string
+	}
+
+	defaultStore := redisobj.NewStore(redisClient)
+	serviceStore := redisobj.NewStore(redisClient, redisobj.Namespace("service"))
+	tenantStore := serviceStore.WithNamespace("tenantA")
+
+	testCases := []struct {
+		description string
+		store       *redisobj.Store
+		value       string
+		expectedKey string
+	}{
+		{
+			description: "default namespace",
+			store:       defaultStore,
+			value:       "default",
+			expectedKey: "{redisobj:root:UUID}",
+		},
+		{
+			description: "store namespace",
+			store:       serviceStore,
+			value:       "service",
+			expectedKey: "{service:root:UUID}",
+		},
+		{
+			description: "child store namespace",
+			store:       tenantStore,
+			value:       "tenant",
+			expectedKey: "{service::tenantA:root:UUID}",
+		},
+	}
+	for _, testCase := range testCases {
+		err := testCase.store.Write(ctx, &root{Id: "UUID", String: testCase.value}, redisobj.Options{})
+		assert.Nil(t, err)
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.description, func(t *testing.T) {
+			actualObject := &root{
+				Id: "UUID",
+			}
+			err := testCase.store.Read(ctx, actualObject, redisobj.Options{})
+			assert.Nil(t, err)
+			assert.Equal(t, testCase.value, actualObject.String)
+
+			actualValue, err := redisClient.HGet(testCase.expectedKey, "String").Result()
+			assert.Nil(t, err)
+			assert.Equal(t, testCase.value, actualValue)
+		})
+	}
+
+	// A child store's singleton does not share the keys of a parent type named after the child namespace.
+	type tenantA struct {
+		Id     string `redisobj:"key"`
+		String string
+	}
+	type Item struct {
+		String string
+	}
+
+	err := serviceStore.Write(ctx, &tenantA{Id: "Item", String: "parent"}, redisobj.Options{})
+	assert.Nil(t, err)
+	err = tenantStore.Write(ctx, &Item{String: "child"}, redisobj.Options{})
+	assert.Nil(t, err)
+
+	parentObject := &tenantA{Id: "Item"}
+	err = serviceStore.Read(ctx, parentObject, redisobj.Options{})
+	assert.Nil(t, err)
+	assert.Equal(t, "parent", parentObject.String)
+
+	childObject := &Item{}
+	err = tenantStore.Read(ctx, childObject, redisobj.Options{})
+	assert.Nil(t, err)
+	assert.Equal(t, "child", childObject.String)
+
+	actualValue, err := redisClient.HGet("{service::tenantA:Item}", "String").Result()
+	assert.Nil(t, err)
+	assert.Equal(t, "child", actualValue)
+
+	// Empty child namespaces are escaped like empty key values.
+	err = serviceStore.WithNamespace("").Write(ctx, &Item{String: "empty"}, redisobj.Options{})
+	assert.Nil(t, err)
+
+	actualValue, err = redisClient.HGet("{service::%00:Item}", "String").Result()
+	assert.Nil(t, err)
+	assert.Equal(t, "empty", actualValue)
+}
+
+func Test_Store_type_names(t *testing.T) {
+	redisClient := NewGoRedisClient()
+	redisClient.FlushAll()
+	ctx := context.Background()
+
+	type item struct {
+		Id     string `redisobj:"key"`
+		String string
+	}
+	type taggedItem struct {
+		_      struct{} `redisobj:"type=Tagged"`
+		Id     string   `redisobj:"key"`
+		String string
+	}
+	type registeredItem struct {
+		Id     string `redisobj:"key"`
+		String string
+	}
+
+	objStore := redisobj.NewStore(redisClient)
+
+	err := objStore.RegisterType(registeredItem{}, "Registered")
+	assert.Nil(t, err)
+
+	err = objStore.RegisterType(registeredItem{}, "Other")
+	assert.ErrorIs(t, err, redisobj.ErrTypeNameConflict)
+
+	err = objStore.Write(ctx, &item{Id: "UUID", String: "item"}, redisobj.Options{})
+	assert.Nil(t, err)
+	err = objStore.Write(ctx, &taggedItem{Id: "UUID", String: "tagged"}, redisobj.Options{})
+	assert.Nil(t, err)
+	err = objStore.Write(ctx, &registeredItem{Id: "UUID", String: "registered"}, redisobj.Options{})
+	assert.Nil(t, err)
+
+	actualValue, err := redisClient.HGet("{redisobj:item:UUID}", "String").Result()
+	assert.Nil(t, err)
+	assert.Equal(t, "item", actualValue)
+
+	actualValue, err = redisClient.HGet("{redisobj:Tagged:UUID}", "String").Result()
+	assert.Nil(t, err)
+	assert.Equal(t, "tagged", actualValue)
+
+	actualValue, err = redisClient.HGet("{redisobj:Registered:UUID}", "String").Result()
+	assert.Nil(t, err)
+	assert.Equal(t, "registered", actualValue)
+
+	// A different type with the same name must not share the key space.
+	{
+		type item struct {
+			Id    string `redisobj:"key"`
+			Other int
+		}
+
+		err = objStore.Write(ctx, &item{Id: "UUID"}, redisobj.Options{})
+		assert.ErrorIs(t, err, redisobj.ErrTypeNameConflict)
+	}
+
+	// Anonymous structs must be named.
+	err = objStore.Write(ctx, &struct{ String string }{String: "anonymous"}, redisobj.Options{})
+	assert.ErrorIs(t, err, redisobj.ErrInvalidObject)
+
+	// Unkeyed anonymous nested structs are stored under their field name.
+	type located struct {
+		Id  string `redisobj:"key"`
+		Geo struct {
+			Lat float64
+		}
+	}
+	object := &located{Id: "UUID"}
+	object.Geo.Lat = 1.5
+	err = objStore.Write(ctx, object, redisobj.Options{})
+	assert.Nil(t, err)
+
+	actualValue, err = redisClient.HGet("{redisobj:located:UUID}:Geo", "Lat").Result()
+	assert.Nil(t, err)
+	assert.Equal(t, "1.5", actualValue)
+
+	actualObject := &located{Id: "UUID"}
+	err = objStore.Read(ctx, actualObject, redisobj.Options{})
+	assert.Nil(t, err)
+	assert.Equal(t, object, actualObject)
+
+	// Keyed anonymous nested structs are stored on their own and must be named.
+	type sharing struct {
+		Id     string `redisobj:"key"`
+		Shared struct {
+			Id string `redisobj:"key"`
+		}
+	}
+	err = objStore.Write(ctx, &sharing{Id: "UUID"}, redisobj.Options{})
+	assert.ErrorIs(t, err, redisobj.ErrInvalidObject)
+}
+
+func Test_Store_Register(t *testing.T) {
+	redisClient := NewGoRedisClient()
+	redisClient.FlushAll()
+	ctx := context.Background()
+
+	type nested struct {
+		Pointer *int
+	}
+	type invalid struct {
+		Id      string `redisobj:"key"`
+		Channel chan int
+		Slice   []nested
+		Nested  nested
+	}
+	type valid struct {
+		Id     string `redisobj:"key"`
+		String string
+	}
+
+	objStore := redisobj.NewStore(redisClient, redisobj.RequireRegistration())
+
+	err := objStore.Write(ctx, &valid{Id: "UUID"}, redisobj.Options{})
+	assert.ErrorIs(t, err, redisobj.ErrTypeNotRegistered)
+
+	err = objStore.Register(valid{}, invalid{})
+	assert.ErrorIs(t, err, redisobj.ErrInvalidFieldType)
+
+	var multiErr redisobj.MultiError
+	assert.ErrorAs(t, err, &multiErr)
+	assert.Len(t, multiErr, 3)
+	assert.Contains(t, err.Error(), "invalid.Channel")
+	assert.Contains(t, err.Error(), "invalid.Slice")
+	assert.Contains(t, err.Error(), "invalid.Nested.Pointer")
+
+	assert.Panics(t, func() {
+		objStore.MustRegister(invalid{})
+	})
+
+	err = objStore.Write(ctx, &valid{Id: "UUID"}, redisobj.Options{})
+	assert.Nil(t, err)
+
+	err = objStore.Write(ctx, &invalid{Id: "UUID"}, redisobj.Options{})
+	assert.ErrorIs(t, err, redisobj.ErrTypeNotRegistered)
+}
+
+func Test_Store_concurrent_first_use(t *testing.T) {
+	redisClient := NewGoRedisClient()
+	redisClient.FlushAll()
+	ctx := context.Background()
+
+	type root struct {
+		Id     string `redisobj:"key"`
+		String string
+		Map    map[string]int
+	}
+
+	objStore := redisobj.NewStore(redisClient)
+
+	waitGroup := sync.WaitGroup{}
+	for i := 0; i < 50; i++ {
+		waitGroup.Add(1)
+		go func(id string) {
+			defer waitGroup.Done()
+
+			err := objStore.Write(ctx, &root{Id: id, String: id, Map: map[string]int{id: 1}}, redisobj.Options{})
+			assert.Nil(t, err)
+
+			actualObject := &root{
+				Id: id,
+			}
+			err = objStore.Read(ctx, actualObject, redisobj.Options{})
+			assert.Nil(t, err)
+			assert.Equal(t, id, actualObject.String)
+		}(strconv.Itoa(i))
+	}
+	waitGroup.Wait()
+}
+
+type plannedObject struct {
+	Id    string `redisobj:"key"`
+	Value string
+	Slice []string
+	Map   map[string]string
+}
+
+func init() {
+	// Plans that prefix every value, to verify that the Store uses plans over reflection.
+	redisobj.RegisterPlan((*plannedObject)(nil), redisobj.TypePlan{
+		Fields: map[string]redisobj.FieldPlan{
+			"Value": {
+				EncodeValue: func(obj interface{}, encoder *redisobj.Encoder) {
+					encoder.AppendString("plan:" + obj.(*plannedObject).Value)
+				},
+				DecodeValue: func(obj interface{}, value string) error {
+					obj.(*plannedObject).Value = value
+					return nil
+				},
+			},
+			"Slice": {
+				EncodeSlice: func(obj interface{}, encoder *redisobj.Encoder) {
+					for _, element := range obj.(*plannedObject).Slice {
+						encoder.AppendString("plan:" + element)
+					}
+				},
+				DecodeSlice: func(obj interface{}, values []string) error {
+					obj.(*plannedObject).Slice = values
+					return nil
+				},
+			},
+			"Map": {
+				EncodeMap: func(obj interface{}, encoder *redisobj.Encoder) {
+					for key, element := range obj.(*plannedObject).Map {
+						encoder.AppendString(key)
+						encoder.AppendString("plan:" + element)
+					}
+				},
+				DecodeMap: func(obj interface{}, values map[string]string) error {
+					obj.(*plannedObject).Map = values
+					return nil
+				},
+			},
+		},
+	})
+}
+
+func Test_Store_generated_plan(t *testing.T) {
+	redisClient := NewGoRedisClient()
+	redisClient.FlushAll()
+	ctx := context.Background()
+
+	objStore := redisobj.NewStore(redisClient)
+
+	// Written by value to ensure plans work with non addressable objects.
+	err := objStore.Write(ctx, plannedObject{
+		Id:    "UUID",
+		Value: "value",
+		Slice: []string{"one"},
+		Map:   map[string]string{"key": "value"},
+	}, redisobj.Options{})
+	assert.Nil(t, err)
+
+	actualObject := &plannedObject{
+		Id: "UUID",
+	}
+	err = objStore.Read(ctx, actualObject, redisobj.Options{})
+	assert.Nil(t, err)
+	assert.Equal(t, &plannedObject{
+		Id:    "UUID",
+		Value: "plan:value",
+		Slice: []string{"plan:one"},
+		Map:   map[string]string{"key": "plan:value"},
+	}, actualObject)
+}
+
+// roundTripCounter counts the commands and pipelines sent to redis.
+type roundTripCounter struct {
+	roundTrips int
+	commands   []string
+}
+
+func (self *roundTripCounter) BeforeProcess(ctx context.Context, cmd redis.Cmder) (context.Context, error) {
+	self.roundTrips++
+	self.commands = append(self.commands, cmd.Name())
+	return ctx, nil
+}
+
+func (self *roundTripCounter) AfterProcess(ctx context.Context, cmd redis.Cmder) error {
+	return nil
+}
+
+func (self *roundTripCounter) BeforeProcessPipeline(ctx context.Context, cmds []redis.Cmder) (context.Context, error) {
+	self.roundTrips++
+	for _, cmd := range cmds {
+		self.commands = append(self.commands, cmd.Name())
+	}
+	return ctx, nil
+}
+
+func (self *roundTripCounter) AfterProcessPipeline(ctx context.Context, cmds []redis.Cmder) error {
+	return nil
+}
+
+func Test_Store_cache_round_trips(t *testing.T) {
+	redisClient := NewGoRedisClient()
+	redisClient.FlushAll()
+	ctx := context.Background()
+
+	type nested struct {
+		Id     string `redisobj:"key"`
+		String string
+	}
+	type root struct {
+		Id     string `redisobj:"key"`
+		String string
+		Map    map[string]int
+		Nested nested
+	}
+
+	counter := &roundTripCounter{}
+	redisClient.AddHook(counter)
+
+	objStore := redisobj.NewStore(redisClient)
+	options := redisobj.Options{
+		EnableCaching: true,
+	}
+	object := &root{
+		Id:     "UUID",
+		String: "string",
+		Map:    map[string]int{"one": 1},
+		Nested: nested{
+			Id:     "NESTED",
+			String: "nested",
+		},
+	}
+
+	// The first write checks the hashes and then writes the data.
+	err := objStore.Write(ctx, object, options)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, counter.roundTrips)
+
+	// An unchanged object only sends the hashes.
+	counter.roundTrips, counter.commands = 0, nil
+	err = objStore.Write(ctx, object, options)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, counter.roundTrips)
+	assert.Equal(t, []string{"set", "set"}, counter.commands)
+
+	// A changed object is written again.
+	object.Map = map[string]int{"two": 2}
+	counter.roundTrips, counter.commands = 0, nil
+	err = objStore.Write(ctx, object, options)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, counter.roundTrips)
+
+	actualObject := &root{
+		Id: "UUID",
+		Nested: nested{
+			Id: "NESTED",
+		},
+	}
+	err = objStore.Read(ctx, actualObject, redisobj.Options{})
+	assert.Nil(t, err)
+	assert.Equal(t, object, actualObject)
+
+	// Reading into an object matching the stored hashes costs one round trip.
+	counter.roundTrips, counter.commands = 0, nil
+	err = objStore.Read(ctx, actualObject, options)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, counter.roundTrips)
+	assert.Equal(t, []string{"get", "get"}, counter.commands)
+
+	// Map hashes do not depend on the iteration order, but do on which key holds which value.
+	object.Map = map[string]int{"one": 1, "two": 2, "three": 3, "four": 4}
+	err = objStore.Write(ctx, object, options)
+	assert.Nil(t, err)
+
+	for range [8]struct{}{} {
+		counter.roundTrips, counter.commands = 0, nil
+		err = objStore.Write(ctx, object, options)
+		assert.Nil(t, err)
+		assert.Equal(t, 1, counter.roundTrips)
+	}
+
+	object.Map = map[string]int{"one": 2, "two": 1, "three": 3, "four": 4}
+	counter.roundTrips, counter.commands = 0, nil
+	err = objStore.Write(ctx, object, options)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, counter.roundTrips)
+
+	// Skipped structs keep the expiry of their data, so that their hash does not outlive it.
+	object.Nested.String = "expiring"
+	err = objStore.Write(ctx, object, redisobj.Options{EnableCaching: true, Ttl: time.Hour})
+	assert.Nil(t, err)
+	object.String = "changed"
+	err = objStore.Write(ctx, object, redisobj.Options{EnableCaching: true, Ttl: 2 * time.Hour})
+	assert.Nil(t, err)
+
+	for key, expectedTtl := range map[string]time.Duration{
+		"{redisobj:root:UUID}":                2 * time.Hour,
+		"{redisobj:root:UUID}.__EXISTS__":     2 * time.Hour,
+		"{redisobj:root:UUID}.__HASH__":       2 * time.Hour,
+		"{redisobj:nested:NESTED}":            time.Hour,
+		"{redisobj:nested:NESTED}.__EXISTS__": time.Hour,
+		"{redisobj:nested:NESTED}.__HASH__":   time.Hour,
+	} {
+		actualTtl, err := redisClient.TTL(key).Result()
+		assert.Nil(t, err)
+		assert.Equal(t, expectedTtl, actualTtl, key)
+	}
+}
+
+func Test_Store_ReadIfChanged(t *testing.T) {
+	redisClient := NewGoRedisClient()
+	redisClient.FlushAll()
+	ctx := context.Background()
+
+	type root struct {
+		Id     string `redisobj:"key"`
+		String string
+		Slice  []string
+	}
+
+	counter := &roundTripCounter{}
+	redisClient.AddHook(counter)
+
+	objStore := redisobj.NewStore(redisClient)
+	object := &root{
+		Id:     "UUID",
+		String: "string",
+		Slice:  []string{"one"},
+	}
+
+	// Objects written without caching have no etag but can still be read.
+	err := objStore.Write(ctx, object, redisobj.Options{})
+	assert.Nil(t, err)
+
+	actualObject := &root{Id: "UUID"}
+	etag, err := objStore.ReadIfChanged(ctx, actualObject, "", redisobj.Options{})
+	assert.Nil(t, err)
+	assert.Equal(t, "", etag)
+	assert.Equal(t, object, actualObject)
+
+	err = objStore.Write(ctx, object, redisobj.Options{EnableCaching: true})
+	assert.Nil(t, err)
+
+	actualObject = &root{Id: "UUID"}
+	etag, err = objStore.ReadIfChanged(ctx, actualObject, "", redisobj.Options{})
+	assert.Nil(t, err)
+	assert.NotEqual(t, "", etag)
+	assert.Equal(t, object, actualObject)
+
+	// A matching etag only fetches the hash.
+	counter.roundTrips, counter.commands = 0, nil
+	actualObject = &root{Id: "UUID"}
+	unchangedEtag, err := objStore.ReadIfChanged(ctx, actualObject, etag, redisobj.Options{})
+	assert.ErrorIs(t, err, redisobj.ErrNotModified)
+	assert.Equal(t, etag, unchangedEtag)
+	assert.Equal(t, &root{Id: "UUID"}, actualObject)
+	assert.Equal(t, 1, counter.roundTrips)
+	assert.Equal(t, []string{"get"}, counter.commands)
+
+	object.String = "changed"
+	err = objStore.Write(ctx, object, redisobj.Options{EnableCaching: true})
+	assert.Nil(t, err)
+
+	actualObject = &root{Id: "UUID"}
+	changedEtag, err := objStore.ReadIfChanged(ctx, actualObject, etag, redisobj.Options{})
+	assert.Nil(t, err)
+	assert.NotEqual(t, etag, changedEtag)
+	assert.Equal(t, object, actualObject)
+
+	_, err = objStore.ReadIfChanged(ctx, &root{Id: "MISSING"}, etag, redisobj.Options{})
+	assert.ErrorIs(t, err, redisobj.ErrObjectNotFound)
+
+	// Writes without caching remove the stored hash, so the etag no longer matches.
+	etag = changedEtag
+	object.String = "uncached"
+	err = objStore.Write(ctx, object, redisobj.Options{})
+	assert.Nil(t, err)
+
+	actualObject = &root{Id: "UUID"}
+	uncachedEtag, err := objStore.ReadIfChanged(ctx, actualObject, etag, redisobj.Options{})
+	assert.Nil(t, err)
+	assert.Equal(t, "", uncachedEtag)
+	assert.Equal(t, object, actualObject)
+
+	// Nor are cached writes of the data the hash was stored for skipped.
+	object.String = "changed"
+	err = objStore.Write(ctx, object, redisobj.Options{EnableCaching: true})
+	assert.Nil(t, err)
+
+	actualObject = &root{Id: "UUID"}
+	err = objStore.Read(ctx, actualObject, redisobj.Options{})
+	assert.Nil(t, err)
+	assert.Equal(t, "changed", actualObject.String)
+
+	etag, err = objStore.ReadIfChanged(ctx, &root{Id: "UUID"}, "", redisobj.Options{})
+	assert.Nil(t, err)
+	assert.Equal(t, changedEtag, etag)
+
+	// Writing a keyed nested struct on its own changes the etag of the objects containing it.
+	type group struct {
+		Id   string `redisobj:"key"`
+		Name string
+	}
+	type member struct {
+		Id    string `redisobj:"key"`
+		Group group
+	}
+
+	memberObject := &member{Id: "UUID", Group: group{Id: "G", Name: "old"}}
+	err = objStore.Write(ctx, memberObject, redisobj.Options{EnableCaching: true})
+	assert.Nil(t, err)
+
+	etag, err = objStore.ReadIfChanged(ctx, &member{Id: "UUID", Group: group{Id: "G"}}, "", redisobj.Options{})
+	assert.Nil(t, err)
+	assert.NotEqual(t, "", etag)
+
+	err = objStore.Write(ctx, &group{Id: "G", Name: "new"}, redisobj.Options{EnableCaching: true})
+	assert.Nil(t, err)
+
+	actualMember := &member{Id: "UUID", Group: group{Id: "G"}}
+	changedEtag, err = objStore.ReadIfChanged(ctx, actualMember, etag, redisobj.Options{})
+	assert.Nil(t, err)
+	assert.NotEqual(t, etag, changedEtag)
+	assert.Equal(t, &member{Id: "UUID", Group: group{Id: "G", Name: "new"}}, actualMember)
+}
+
+func Test_Store_Delete(t *testing.T) {
+	redisClient := NewGoRedisClient()
+	redisClient.FlushAll()
+	ctx := context.Background()
+
+	type shared struct {
+		Id     string `redisobj:"key"`
+		String string
+	}
+	type nested struct {
+		String string
+		Map    map[string]int
+	}
+	type root struct {
+		Id     string `redisobj:"key"`
+		String string
+		Slice  []string
+		Nested nested
+		Shared shared
+	}
+
+	objStore := redisobj.NewStore(redisClient)
+	object := &root{
+		Id:     "UUID",
+		String: "string",
+		Slice:  []string{"one"},
+		Nested: nested{
+			String: "nested",
+			Map:    map[string]int{"one": 1},
+		},
+		Shared: shared{
+			Id:     "SHARED",
+			String: "shared",
+		},
+	}
+
+	err := objStore.Write(ctx, object, redisobj.Options{EnableCaching: true})
+	assert.Nil(t, err)
+
+	err = objStore.Delete(ctx, &root{Id: "UUID"}, redisobj.Options{})
+	assert.Nil(t, err)
+
+	err = objStore.Read(ctx, &root{Id: "UUID"}, redisobj.Options{})
+	assert.ErrorIs(t, err, redisobj.ErrObjectNotFound)
+
+	// Only the keys of the keyed nested struct remain.
+	keys, err := redisClient.Keys("*").Result()
+	assert.Nil(t, err)
+	assert.ElementsMatch(t, []string{
+		"{redisobj:shared:SHARED}",
+		"{redisobj:shared:SHARED}.__EXISTS__",
+		"{redisobj:shared:SHARED}.__HASH__",
+	}, keys)
+
+	// Deleting a missing object is not an error.
+	err = objStore.Delete(ctx, &root{Id: "UUID"}, redisobj.Options{})
+	assert.Nil(t, err)
+}
+
+func Test_Store_local_cache(t *testing.T) {
+	redisClient := NewGoRedisClient()
+	redisClient.FlushAll()
+	ctx := context.Background()
+
+	type shared struct {
+		Id     string `redisobj:"key"`
+		String string
+	}
+	type root struct {
+		Id     string `redisobj:"key"`
+		String string
+		Map    map[string]int
+		Shared shared
+	}
+
+	// Two stores stand in for two service instances.
+	objStore := redisobj.NewStore(redisClient, redisobj.LocalCache(10, time.Minute))
+	defer objStore.Close()
+	otherStore := redisobj.NewStore(redisClient, redisobj.LocalCache(10, time.Minute))
+	defer otherStore.Close()
+
+	object := &root{
+		Id:     "UUID",
+		String: "string",
+		Map:    map[string]int{"one": 1},
+		Shared: shared{
+			Id:     "SHARED",
+			String: "shared",
+		},
+	}
+	// Written without a local cache, so that no invalidation arrives after the object is cached below.
+	err := redisobj.NewStore(redisClient).Write(ctx, object, redisobj.Options{})
+	assert.Nil(t, err)
+
+	readObject := func(store *redisobj.Store) *root {
+		actualObject := &root{Id: "UUID", Shared: shared{Id: "SHARED"}}
+		err := store.Read(ctx, actualObject, redisobj.Options{})
+		assert.Nil(t, err)
+		return actualObject
+	}
+
+	// Cached objects are served without redis and do not share data with the cache.
+	actualObject := readObject(otherStore)
+	assert.Equal(t, object, actualObject)
+	actualObject.Map["two"] = 2
+
+	redisClient.HSet("{redisobj:root:UUID}", "String", "changed behind the cache")
+	assert.Equal(t, object, readObject(otherStore))
+
+	// A write from another instance evicts the cached object.
+	object.String = "updated"
+	err = objStore.Write(ctx, object, redisobj.Options{})
+	assert.Nil(t, err)
+	assert.Eventually(t, func() bool {
+		return readObject(otherStore).String == "updated"
+	}, time.Second, 10*time.Millisecond)
+
+	// So does a write of a keyed nested struct on its own.
+	err = objStore.Write(ctx, &shared{Id: "SHARED", String: "updated"}, redisobj.Options{})
+	assert.Nil(t, err)
+	assert.Eventually(t, func() bool {
+		return readObject(otherStore).Shared.String == "updated"
+	}, time.Second, 10*time.Millisecond)
+
+	// Reads of the object with a different keyed nested struct are not served the cached one.
+	err = redisobj.NewStore(redisClient).Write(ctx, &shared{Id: "OTHER", String: "other"}, redisobj.Options{})
+	assert.Nil(t, err)
+
+	otherObject := &root{Id: "UUID", Shared: shared{Id: "OTHER"}}
+	err = otherStore.Read(ctx, otherObject, redisobj.Options{})
+	assert.Nil(t, err)
+	assert.Equal(t, shared{Id: "OTHER", String: "other"}, otherObject.Shared)
+
+	// And a delete.
+	err = objStore.Delete(ctx, &root{Id: "UUID"}, redisobj.Options{})
+	assert.Nil(t, err)
+	assert.Eventually(t, func() bool {
+		return otherStore.Read(ctx, &root{Id: "UUID", Shared: shared{Id: "SHARED"}}, redisobj.Options{}) != nil
+	}, time.Second, 10*time.Millisecond)
+}
+
+func Test_Store_local_cache_bounds(t *testing.T) {
+	redisClient := NewGoRedisClient()
+	redisClient.FlushAll()
+	ctx := context.Background()
+
+	type root struct {
+		Id     string `redisobj:"key"`
+		String string
+	}
+
+	// The objects are written without a local cache, so no invalidations are published.
+	for _, id := range []string{"ONE", "TWO"} {
+		err := redisobj.NewStore(redisClient).Write(ctx, &root{Id: id, String: id}, redisobj.Options{})
+		assert.Nil(t, err)
+	}
+
+	objStore := redisobj.NewStore(redisClient, redisobj.LocalCache(1, 50*time.Millisecond))
+	defer objStore.Close()
+
+	readString := func(id string) string {
+		actualObject := &root{Id: id}
+		err := objStore.Read(ctx, actualObject, redisobj.Options{})
+		assert.Nil(t, err)
+		return actualObject.String
+	}
+
+	// Only the most recently read object is kept.
+	assert.Equal(t, "ONE", readString("ONE"))
+	assert.Equal(t, "TWO", readString("TWO"))
+	redisClient.HSet("{redisobj:root:ONE}", "String", "changed")
+	redisClient.HSet("{redisobj:root:TWO}", "String", "changed")
+	assert.Equal(t, "changed", readString("ONE"))
+
+	// Objects expire from the cache after the TTL.
+	redisClient.HSet("{redisobj:root:ONE}", "String", "expired")
+	assert.Equal(t, "changed", readString("ONE"))
+	time.Sleep(60 * time.Millisecond)
+	assert.Equal(t, "expired", readString("ONE"))
+
+	// Objects expire from the cache with their keys in redis, which publish no invalidation when they expire.
+	longStore := redisobj.NewStore(redisClient, redisobj.LocalCache(10, time.Minute))
+	defer longStore.Close()
+
+	err := redisobj.NewStore(redisClient).Write(ctx, &root{Id: "EXPIRING", String: "cached"}, redisobj.Options{Ttl: 50 * time.Millisecond})
+	assert.Nil(t, err)
+
+	actualObject := &root{Id: "EXPIRING"}
+	err = longStore.Read(ctx, actualObject, redisobj.Options{})
+	assert.Nil(t, err)
+	assert.Equal(t, "cached", actualObject.String)
+
+	redisClient.HSet("{redisobj:root:EXPIRING}", "String", "read again")
+	time.Sleep(60 * time.Millisecond)
+
+	actualObject = &root{Id: "EXPIRING"}
+	err = longStore.Read(ctx, actualObject, redisobj.Options{})
+	assert.Nil(t, err)
+	assert.Equal(t, "read again", actualObject.String)
+
+	// Objects expire from the cache with their fields tagged with a ttl as well, even when the cache has no TTL.
+	type viewed struct {
+		Id          string   `redisobj:"key"`
+		RecentViews []string `redisobj:"ttl=50ms"`
+	}
+
+	unboundedStore := redisobj.NewStore(redisClient, redisobj.LocalCache(10, 0))
+	defer unboundedStore.Close()
+
+	err = redisobj.NewStore(redisClient).Write(ctx, &viewed{Id: "VIEWED", RecentViews: []string{"one"}}, redisobj.Options{})
+	assert.Nil(t, err)
+
+	actualViewed := &viewed{Id: "VIEWED"}
+	err = unboundedStore.Read(ctx, actualViewed, redisobj.Options{})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"one"}, actualViewed.RecentViews)
+
+	redisClient.Del("{redisobj:viewed:VIEWED}.RecentViews")
+	time.Sleep(60 * time.Millisecond)
+
+	actualViewed = &viewed{Id: "VIEWED"}
+	err = unboundedStore.Read(ctx, actualViewed, redisobj.Options{})
+	assert.Nil(t, err)
+	assert.Empty(t, actualViewed.RecentViews)
+}
+
+func Test_Store_local_cache_tracking(t *testing.T) {
+	redisClient := NewGoRedisClient()
+	redisClient.FlushAll()
+	ctx := context.Background()
+
+	if err := redisClient.Do("client", "tracking", "off").Err(); err != nil {
+		t.Skipf("redis does not support CLIENT TRACKING: %s", err)
+	}
+
+	type root struct {
+		Id     string `redisobj:"key"`
+		String string
+		Map    map[string]int
+	}
+
+	objStore := redisobj.NewStore(redisClient, redisobj.LocalCache(10, time.Minute), redisobj.LocalCacheTracking())
+	defer objStore.Close()
+
+	err := objStore.Write(ctx, &root{Id: "UUID", String: "string", Map: map[string]int{"one": 1}}, redisobj.Options{})
+	assert.Nil(t, err)
+
+	readObject := func() *root {
+		actualObject := &root{Id: "UUID"}
+		err := objStore.Read(ctx, actualObject, redisobj.Options{})
+		assert.Nil(t, err)
+		return actualObject
+	}
+	assert.Equal(t, map[string]int{"one": 1}, readObject().Map)
+
+	// Changes by any client to any key of the object are pushed by redis, including map and slice keys.
+	redisClient.HSet("{redisobj:root:UUID}.Map", "two", 2)
+	assert.Eventually(t, func() bool {
+		return readObject().Map["two"] == 2
+	}, time.Second, 10*time.Millisecond)
+
+	redisClient.HSet("{redisobj:root:UUID}", "String", "changed")
+	assert.Eventually(t, func() bool {
+		return readObject().String == "changed"
+	}, time.Second, 10*time.Millisecond)
+}
+
+func Test_Store_ReadOrLoad(t *testing.T) {
+	redisClient := NewGoRedisClient()
+	redisClient.FlushAll()
+	ctx := context.Background()
+
+	type root struct {
+		Id     string `redisobj:"key"`
+		String string
+		Slice  []string
+	}
+
+	objStore := redisobj.NewStore(redisClient)
+
+	loads := 0
+	release := make(chan struct{})
+	loader := redisobj.LoaderFunc(func(ctx context.Context, obj interface{}) error {
+		object := obj.(*root)
+		if object.Id == "MISSING" {
+			return redisobj.ErrObjectNotFound
+		}
+
+		loads++
+		<-release
+		object.String = "loaded"
+		object.Slice = []string{"one", "two"}
+		return nil
+	})
+	options := redisobj.Options{
+		Ttl: time.Minute,
+	}
+	expectedObject := &root{
+		Id:     "UUID",
+		String: "loaded",
+		Slice:  []string{"one", "two"},
+	}
+
+	// Concurrent misses only load once.
+	waitGroup := sync.WaitGroup{}
+	for i := 0; i < 20; i++ {
+		waitGroup.Add(1)
+		go func() {
+			defer waitGroup.Done()
+
+			actualObject := &root{Id: "UUID"}
+			err := objStore.ReadOrLoad(ctx, actualObject, loader, options)
+			assert.Nil(t, err)
+			assert.Equal(t, expectedObject, actualObject)
+		}()
+	}
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	waitGroup.Wait()
+	assert.Equal(t, 1, loads)
+
+	// The loaded object was written with the options.
+	actualObject := &root{Id: "UUID"}
+	err := objStore.Read(ctx, actualObject, redisobj.Options{})
+	assert.Nil(t, err)
+	assert.Equal(t, expectedObject, actualObject)
+
+	actualTtl, err := redisClient.TTL("{redisobj:root:UUID}").Result()
+	assert.Nil(t, err)
+	assert.Equal(t, time.Minute, actualTtl)
+
+	// Hits do not load.
+	actualObject = &root{Id: "UUID"}
+	err = objStore.ReadOrLoad(ctx, actualObject, loader, options)
+	assert.Nil(t, err)
+	assert.Equal(t, expectedObject, actualObject)
+	assert.Equal(t, 1, loads)
+
+	// Loader errors are returned.
+	err = objStore.ReadOrLoad(ctx, &root{Id: "MISSING"}, loader, options)
+	assert.ErrorIs(t, err, redisobj.ErrObjectNotFound)
+}
+
+// recordingPersister records the persisted objects, failing the first failures calls.
+type recordingPersister struct {
+	mutex    sync.Mutex
+	failures int
+	objs     []interface{}
+	batches  int
+}
+
+func (self *recordingPersister) Write(obj interface{}) error {
+	self.mutex.Lock()
+	defer self.mutex.Unlock()
+
+	if self.failures > 0 {
+		self.failures--
+		return errors.New("database unavailable")
+	}
+
+	self.objs = append(self.objs, obj)
+	return nil
+}
+
+func (self *recordingPersister) persisted() []interface{} {
+	self.mutex.Lock()
+	defer self.mutex.Unlock()
+
+	return append([]interface{}{}, self.objs...)
+}
+
+// batchPersister persists write-behind batches with a single call.
+type batchPersister struct {
+	recordingPersister
+}
+
+func (self *batchPersister) WriteBatch(objs []interface{}) error {
+	self.mutex.Lock()
+	self.batches++
+	self.mutex.Unlock()
+
+	for _, obj := range objs {
+		if err := self.Write(obj); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func Test_Store_persister_write_through(t *testing.T) {
+	redisClient := NewGoRedisClient()
+	redisClient.FlushAll()
+	ctx := context.Background()
+
+	type root struct {
+		Id     string `redisobj:"key"`
+		String string
+	}
+
+	objStore := redisobj.NewStore(redisClient)
+	defer objStore.Close()
+
+	persister := &recordingPersister{}
+	err := objStore.AttachPersister(&root{}, persister, redisobj.PersistOptions{
+		Mode: redisobj.WriteThrough,
+	})
+	assert.Nil(t, err)
+
+	object := &root{Id: "UUID", String: "string"}
+	err = objStore.Write(ctx, object, redisobj.Options{EnableCaching: true})
+	assert.Nil(t, err)
+	assert.Equal(t, []interface{}{object}, persister.persisted())
+
+	// Unchanged objects are not persisted again.
+	err = objStore.Write(ctx, object, redisobj.Options{EnableCaching: true})
+	assert.Nil(t, err)
+	assert.Len(t, persister.persisted(), 1)
+
+	// Objects that fail to persist are not written to redis.
+	persister.failures = 1
+	err = objStore.Write(ctx, &root{Id: "FAILED"}, redisobj.Options{})
+	assert.ErrorIs(t, err, redisobj.ErrPersistFailure)
+
+	err = objStore.Read(ctx, &root{Id: "FAILED"}, redisobj.Options{})
+	assert.ErrorIs(t, err, redisobj.ErrObjectNotFound)
+
+	// With caching, the hash check does not record new objects as existing before they are written.
+	persister.failures = 1
+	err = objStore.Write(ctx, &root{Id: "FAILED_CACHED"}, redisobj.Options{EnableCaching: true})
+	assert.ErrorIs(t, err, redisobj.ErrPersistFailure)
+
+	exists, err := redisClient.Exists("{redisobj:root:FAILED_CACHED}.__EXISTS__", "{redisobj:root:FAILED_CACHED}.__HASH__").Result()
+	assert.Nil(t, err)
+	assert.Equal(t, int64(0), exists)
+
+	err = objStore.Read(ctx, &root{Id: "FAILED_CACHED"}, redisobj.Options{})
+	assert.ErrorIs(t, err, redisobj.ErrObjectNotFound)
+	assert.False(t, errors.Is(err, redisobj.ErrPartialObject))
+}
+
+func Test_Store_persister_write_behind(t *testing.T) {
+	redisClient := NewGoRedisClient()
+	redisClient.FlushAll()
+	ctx := context.Background()
+
+	type root struct {
+		Id    string `redisobj:"key"`
+		Slice []string
+	}
+
+	objStore := redisobj.NewStore(redisClient)
+
+	// One failure is retried.
+	persister := &batchPersister{}
+	persister.failures = 1
+	err := objStore.AttachPersister(&root{}, persister, redisobj.PersistOptions{
+		Mode:          redisobj.WriteBehind,
+		BatchSize:     2,
+		FlushInterval: 10 * time.Millisecond,
+		RetryDelay:    time.Millisecond,
+	})
+	assert.Nil(t, err)
+
+	expectedObjects := []interface{}{}
+	for _, id := range []string{"ONE", "TWO", "THREE"} {
+		object := &root{Id: id, Slice: []string{id}}
+		expectedObjects = append(expectedObjects, object)
+
+		err = objStore.Write(ctx, object, redisobj.Options{})
+		assert.Nil(t, err)
+	}
+
+	// Objects are persisted in write order, in batches.
+	assert.Eventually(t, func() bool {
+		return len(persister.persisted()) == 3
+	}, time.Second, 10*time.Millisecond)
+	assert.Equal(t, expectedObjects, persister.persisted())
+	assert.GreaterOrEqual(t, persister.batches, 2)
+
+	queueLength, err := redisClient.LLen("{redisobj:__PERSIST__:root}").Result()
+	assert.Nil(t, err)
+	assert.Equal(t, int64(0), queueLength)
+
+	// Attaching another Persister stops the previous one, which persists nothing afterwards.
+	replacement := &batchPersister{}
+	err = objStore.AttachPersister(&root{}, replacement, redisobj.PersistOptions{
+		Mode:          redisobj.WriteBehind,
+		FlushInterval: time.Millisecond,
+	})
+	assert.Nil(t, err)
+
+	for index := 0; index < 20; index++ {
+		err = objStore.Write(ctx, &root{Id: strconv.Itoa(index)}, redisobj.Options{})
+		assert.Nil(t, err)
+		time.Sleep(time.Millisecond)
+	}
+
+	assert.Eventually(t, func() bool {
+		return len(replacement.persisted()) == 20
+	}, time.Second, 10*time.Millisecond)
+	assert.Equal(t, expectedObjects, persister.persisted())
+
+	assert.Nil(t, objStore.Close())
+}
+
+func Test_Store_persister_write_behind_failures(t *testing.T) {
+	redisClient := NewGoRedisClient()
+	redisClient.FlushAll()
+	ctx := context.Background()
+
+	type root struct {
+		Id string `redisobj:"key"`
+	}
+
+	objStore := redisobj.NewStore(redisClient)
+
+	// Batches still failing after the retries are moved to the failed queue.
+	errs := make(chan error, 10)
+	persister := &recordingPersister{failures: 3}
+	err := objStore.AttachPersister(&root{}, persister, redisobj.PersistOptions{
+		Mode:          redisobj.WriteBehind,
+		FlushInterval: time.Hour,
+		MaxRetries:    2,
+		RetryDelay:    time.Millisecond,
+		OnError: func(err error) {
+			errs <- err
+		},
+	})
+	assert.Nil(t, err)
+
+	err = objStore.Write(ctx, &root{Id: "FAILED"}, redisobj.Options{})
+	assert.Nil(t, err)
+
+	// Close flushes the queue even though the flush interval has not passed.
+	err = objStore.Close()
+	assert.ErrorIs(t, err, redisobj.ErrPersistFailure)
+	assert.NotZero(t, len(errs))
+	assert.Empty(t, persister.persisted())
+
+	failedLength, err := redisClient.LLen("{redisobj:__PERSIST__:root}.__FAILED__").Result()
+	assert.Nil(t, err)
+	assert.Equal(t, int64(1), failedLength)
+
+	// The next object is persisted by the flush on close.
+	objStore = redisobj.NewStore(redisClient)
+	err = objStore.AttachPersister(&root{}, persister, redisobj.PersistOptions{
+		Mode:          redisobj.WriteBehind,
+		FlushInterval: time.Hour,
+	})
+	assert.Nil(t, err)
+
+	err = objStore.Write(ctx, &root{Id: "PERSISTED"}, redisobj.Options{})
+	assert.Nil(t, err)
+
+	err = objStore.Close()
+	assert.Nil(t, err)
+	assert.Equal(t, []interface{}{&root{Id: "PERSISTED"}}, persister.persisted())
+}
+
+func Test_Store_ReadOrLoad_missing(t *testing.T) {
+	redisClient := NewGoRedisClient()
+	redisClient.FlushAll()
+	ctx := context.Background()
+
+	type root struct {
+		Id     string `redisobj:"key"`
+		String string
+	}
+
+	objStore := redisobj.NewStore(redisClient)
+
+	loads := 0
+	loader := redisobj.LoaderFunc(func(ctx context.Context, obj interface{}) error {
+		loads++
+		return redisobj.ErrObjectNotFound
+	})
+	options := redisobj.Options{
+		MissingTtl: 30 * time.Second,
+	}
+
+	err := objStore.ReadOrLoad(ctx, &root{Id: "UUID"}, loader, options)
+	assert.ErrorIs(t, err, redisobj.ErrObjectNotFound)
+	assert.NotErrorIs(t, err, redisobj.ErrObjectKnownMissing)
+	assert.Equal(t, 1, loads)
+
+	actualTtl, err := redisClient.TTL("{redisobj:root:UUID}.__EXISTS__").Result()
+	assert.Nil(t, err)
+	assert.Equal(t, 30*time.Second, actualTtl)
+
+	// The missing object is not loaded again while it is recorded as missing.
+	err = objStore.ReadOrLoad(ctx, &root{Id: "UUID"}, loader, options)
+	assert.ErrorIs(t, err, redisobj.ErrObjectKnownMissing)
+	assert.Equal(t, 1, loads)
+
+	// Reads report it as missing, which is a kind of not found.
+	err = objStore.Read(ctx, &root{Id: "UUID"}, redisobj.Options{})
+	assert.ErrorIs(t, err, redisobj.ErrObjectKnownMissing)
+	assert.ErrorIs(t, err, redisobj.ErrObjectNotFound)
+
+	// Writing the object replaces the record.
+	err = objStore.Write(ctx, &root{Id: "UUID", String: "string"}, redisobj.Options{})
+	assert.Nil(t, err)
+
+	actualObject := &root{Id: "UUID"}
+	err = objStore.ReadOrLoad(ctx, actualObject, loader, options)
+	assert.Nil(t, err)
+	assert.Equal(t, &root{Id: "UUID", String: "string"}, actualObject)
+
+	// Without MissingTtl, nothing is recorded.
+	err = objStore.ReadOrLoad(ctx, &root{Id: "OTHER"}, loader, redisobj.Options{})
+	assert.ErrorIs(t, err, redisobj.ErrObjectNotFound)
+	err = objStore.ReadOrLoad(ctx, &root{Id: "OTHER"}, loader, redisobj.Options{})
+	assert.NotErrorIs(t, err, redisobj.ErrObjectKnownMissing)
+	assert.Equal(t, 3, loads)
+}
+
+func Test_Store_EarlyRefresh(t *testing.T) {
+	redisClient := NewGoRedisClient()
+	redisClient.FlushAll()
+	ctx := context.Background()
+
+	type root struct {
+		Id     string `redisobj:"key"`
+		String string
+	}
+
+	objStore := redisobj.NewStore(redisClient)
+
+	loads := make(chan string, 10)
+	err := objStore.RegisterLoader(&root{}, redisobj.LoaderFunc(func(ctx context.Context, obj interface{}) error {
+		object := obj.(*root)
+		loads <- object.Id
+		object.String = "refreshed"
+		return nil
+	}))
+	assert.Nil(t, err)
+
+	readString := func(id string, options redisobj.Options) string {
+		actualObject := &root{Id: id}
+		err := objStore.Read(ctx, actualObject, options)
+		assert.Nil(t, err)
+		return actualObject.String
+	}
+
+	err = objStore.Write(ctx, &root{Id: "UUID", String: "stale"}, redisobj.Options{Ttl: time.Minute})
+	assert.Nil(t, err)
+	err = objStore.Write(ctx, &root{Id: "PERSISTENT", String: "stale"}, redisobj.Options{})
+	assert.Nil(t, err)
+
+	// Without early refresh, reads never load.
+	assert.Equal(t, "stale", readString("UUID", redisobj.Options{}))
+	assert.Len(t, loads, 0)
+
+	// Objects without a TTL are never refreshed.
+	options := redisobj.Options{
+		Ttl: time.Minute,
+		// A large factor refreshes objects long before they expire.
+		EarlyRefresh: 1e6,
+	}
+	assert.Equal(t, "stale", readString("PERSISTENT", options))
+	assert.Len(t, loads, 0)
+
+	// The read returns the stored object while the refresh runs in the background.
+	assert.Equal(t, "stale", readString("UUID", options))
+	assert.Equal(t, "UUID", <-loads)
+	assert.Eventually(t, func() bool {
+		return readString("UUID", redisobj.Options{}) == "refreshed"
+	}, time.Second, 10*time.Millisecond)
+
+	// The registered loader is used by ReadOrLoad without a loader.
+	actualObject := &root{Id: "MISSING"}
+	err = objStore.ReadOrLoad(ctx, actualObject, nil, redisobj.Options{})
+	assert.Nil(t, err)
+	assert.Equal(t, "refreshed", actualObject.String)
+
+	err = redisobj.NewStore(redisClient).ReadOrLoad(ctx, &root{Id: "UNREGISTERED"}, nil, redisobj.Options{})
+	assert.ErrorIs(t, err, redisobj.ErrLoaderNotRegistered)
+}
+
+func Test_Store_SlidingTtl(t *testing.T) {
+	redisClient := NewGoRedisClient()
+	redisClient.FlushAll()
+	ctx := context.Background()
+
+	type shared struct {
+		Id     string `redisobj:"key"`
+		String string
+	}
+	type nested struct {
+		Slice []string
+	}
+	type root struct {
+		Id     string `redisobj:"key"`
+		String string
+		Map    map[string]int
+		Nested nested
+		Shared shared
+	}
+
+	objStore := redisobj.NewStore(redisClient)
+
+	object := &root{
+		Id:     "UUID",
+		String: "string",
+		Map:    map[string]int{"one": 1},
+		Nested: nested{Slice: []string{"one"}},
+		Shared: shared{Id: "SHARED", String: "shared"},
+	}
+	err := objStore.Write(ctx, object, redisobj.Options{EnableCaching: true, Ttl: time.Minute})
+	assert.Nil(t, err)
+
+	actualObject := &root{Id: "UUID", Shared: shared{Id: "SHARED"}}
+	err = objStore.Read(ctx, actualObject, redisobj.Options{SlidingTtl: time.Hour})
+	assert.Nil(t, err)
+	assert.Equal(t, object, actualObject)
+
+	for _, key := range []string{
+		"{redisobj:root:UUID}",
+		"{redisobj:root:UUID}.__EXISTS__",
+		"{redisobj:root:UUID}.__HASH__",
+		"{redisobj:root:UUID}.Map",
+		"{redisobj:root:UUID}:nested.Slice",
+		"{redisobj:shared:SHARED}",
+		"{redisobj:shared:SHARED}.__EXISTS__",
+		"{redisobj:shared:SHARED}.__HASH__",
+	} {
+		actualTtl, err := redisClient.TTL(key).Result()
+		assert.Nil(t, err)
+		assert.Equal(t, time.Hour, actualTtl, key)
+	}
+
+	// Missing objects are not recreated.
+	err = objStore.Read(ctx, &root{Id: "MISSING"}, redisobj.Options{SlidingTtl: time.Hour})
+	assert.ErrorIs(t, err, redisobj.ErrObjectNotFound)
+
+	exists, err := redisClient.Exists("{redisobj:root:MISSING}.__EXISTS__").Result()
+	assert.Nil(t, err)
+	assert.Equal(t, int64(0), exists)
+
+	// Reads served from the local cache slide the expiry as well.
+	cachingStore := redisobj.NewStore(redisClient, redisobj.LocalCache(10, time.Minute))
+	defer cachingStore.Close()
+
+	// Written without a local cache, so that no invalidation arrives after the object is cached below.
+	err = objStore.Write(ctx, object, redisobj.Options{Ttl: time.Hour})
+	assert.Nil(t, err)
+
+	actualObject = &root{Id: "UUID", Shared: shared{Id: "SHARED"}}
+	err = cachingStore.Read(ctx, actualObject, redisobj.Options{SlidingTtl: time.Hour})
+	assert.Nil(t, err)
+
+	for _, key := range []string{"{redisobj:root:UUID}", "{redisobj:root:UUID}.__EXISTS__", "{redisobj:shared:SHARED}.__EXISTS__"} {
+		err = redisClient.PExpire(key, 5*time.Second).Err()
+		assert.Nil(t, err)
+	}
+
+	counter := &roundTripCounter{}
+	redisClient.AddHook(counter)
+
+	actualObject = &root{Id: "UUID", Shared: shared{Id: "SHARED"}}
+	err = cachingStore.Read(ctx, actualObject, redisobj.Options{SlidingTtl: time.Hour})
+	assert.Nil(t, err)
+	assert.Equal(t, object, actualObject)
+	assert.Equal(t, []string{"eval", "eval"}, counter.commands)
+
+	for _, key := range []string{"{redisobj:root:UUID}", "{redisobj:root:UUID}.__EXISTS__", "{redisobj:shared:SHARED}.__EXISTS__"} {
+		actualTtl, err := redisClient.TTL(key).Result()
+		assert.Nil(t, err)
+		assert.Equal(t, time.Hour, actualTtl, key)
+	}
+}
+
+func Test_Store_ExpireAt_KeepTtl(t *testing.T) {
+	redisClient := NewGoRedisClient()
+	redisClient.FlushAll()
+	ctx := context.Background()
+
+	type nested struct {
+		Slice []string
+	}
+	type root struct {
+		Id     string `redisobj:"key"`
+		String string
+		Map    map[string]int
+		Nested nested
+	}
+
+	objStore := redisobj.NewStore(redisClient)
+
+	keys := []string{
+		"{redisobj:root:UUID}",
+		"{redisobj:root:UUID}.__EXISTS__",
+		"{redisobj:root:UUID}.__HASH__",
+		"{redisobj:root:UUID}.Map",
+		"{redisobj:root:UUID}:nested.Slice",
+	}
+	assertTtls := func(expectedTtl time.Duration) {
+		for _, key := range keys {
+			actualTtl, err := redisClient.TTL(key).Result()
+			assert.Nil(t, err)
+			assert.InDelta(t, expectedTtl.Seconds(), actualTtl.Seconds(), 2, key)
+		}
+	}
+
+	object := &root{
+		Id:     "UUID",
+		String: "string",
+		Map:    map[string]int{"one": 1},
+		Nested: nested{Slice: []string{"one"}},
+	}
+	err := objStore.Write(ctx, object, redisobj.Options{EnableCaching: true, ExpireAt: time.Now().Add(time.Hour)})
+	assert.Nil(t, err)
+	assertTtls(time.Hour)
+
+	// Keeping the TTL leaves the expiry of every key untouched, including rewritten keys.
+	object.String = "changed"
+	object.Map["two"] = 2
+	object.Nested.Slice = append(object.Nested.Slice, "two")
+	err = objStore.Write(ctx, object, redisobj.Options{EnableCaching: true, KeepTtl: true})
+	assert.Nil(t, err)
+	assertTtls(time.Hour)
+
+	// Writes without caching remove the hash instead.
+	err = objStore.Write(ctx, object, redisobj.Options{KeepTtl: true})
+	assert.Nil(t, err)
+	keys = append(keys[:2], keys[3:]...)
+	assertTtls(time.Hour)
+
+	actualObject := &root{Id: "UUID"}
+	err = objStore.Read(ctx, actualObject, redisobj.Options{})
+	assert.Nil(t, err)
+	assert.Equal(t, object, actualObject)
+
+	// New objects written with KeepTtl do not expire.
+	err = objStore.Write(ctx, &root{Id: "NEW", String: "new"}, redisobj.Options{KeepTtl: true})
+	assert.Nil(t, err)
+
+	actualTtl, err := redisClient.TTL("{redisobj:root:NEW}.__EXISTS__").Result()
+	assert.Nil(t, err)
+	assert.Equal(t, time.Duration(ttlInfinite), actualTtl)
+}
+
+func Test_Store_field_ttl(t *testing.T) {
+	redisClient := NewGoRedisClient()
+	redisClient.FlushAll()
+	ctx := context.Background()
+
+	type nested struct {
+		Tags map[string]string `redisobj:"ttl=30m"`
+	}
+	type root struct {
+		Id          string `redisobj:"key"`
+		String      string
+		RecentViews []string `redisobj:"ttl=1h"`
+		Nested      nested
+	}
+
+	objStore := redisobj.NewStore(redisClient)
+
+	object := &root{
+		Id:          "UUID",
+		String:      "string",
+		RecentViews: []string{"one", "two"},
+		Nested:      nested{Tags: map[string]string{"one": "1"}},
+	}
+	err := objStore.Write(ctx, object, redisobj.Options{EnableCaching: true})
+	assert.Nil(t, err)
+
+	for key, expectedTtl := range map[string]time.Duration{
+		"{redisobj:root:UUID}":             ttlInfinite,
+		"{redisobj:root:UUID}.__EXISTS__":  ttlInfinite,
+		"{redisobj:root:UUID}.RecentViews": time.Hour,
+		"{redisobj:root:UUID}:nested.Tags": 30 * time.Minute,
+		"{redisobj:root:UUID}.__HASH__":    30 * time.Minute,
+	} {
+		actualTtl, err := redisClient.TTL(key).Result()
+		assert.Nil(t, err)
+		assert.Equal(t, expectedTtl, actualTtl, key)
+	}
+
+	// Sliding the object expiry leaves the fields with a ttl alone.
+	err = objStore.Read(ctx, &root{Id: "UUID"}, redisobj.Options{SlidingTtl: 2 * time.Hour})
+	assert.Nil(t, err)
+
+	actualTtl, err := redisClient.TTL("{redisobj:root:UUID}.RecentViews").Result()
+	assert.Nil(t, err)
+	assert.Equal(t, time.Hour, actualTtl)
+
+	// Expired fields read as empty.
+	err = redisClient.Del("{redisobj:root:UUID}.RecentViews", "{redisobj:root:UUID}:nested.Tags", "{redisobj:root:UUID}.__HASH__").Err()
+	assert.Nil(t, err)
+
+	actualObject := &root{Id: "UUID"}
+	err = objStore.Read(ctx, actualObject, redisobj.Options{})
+	assert.Nil(t, err)
+	assert.Equal(t, &root{Id: "UUID", String: "string", RecentViews: []string{}, Nested: nested{Tags: map[string]string{}}}, actualObject)
+
+	// Once the hash expired with the fields, writing the object again is not skipped.
+	err = objStore.Write(ctx, object, redisobj.Options{EnableCaching: true})
+	assert.Nil(t, err)
+
+	actualObject = &root{Id: "UUID"}
+	err = objStore.Read(ctx, actualObject, redisobj.Options{})
+	assert.Nil(t, err)
+	assert.Equal(t, object, actualObject)
+
+	type invalid struct {
+		Id     string `redisobj:"key"`
+		String string `redisobj:"ttl=1h"`
+		Slice  []int  `redisobj:"ttl=soon"`
+	}
+	err = objStore.Register(invalid{})
+	assert.ErrorIs(t, err, redisobj.ErrInvalidRedisDefinition)
+	assert.Len(t, err, 2)
+}
+
+func Test_Store_partial_object(t *testing.T) {
+	redisClient := NewGoRedisClient()
+	redisClient.FlushAll()
+	ctx := context.Background()
+
+	type shared struct {
+		Id     string `redisobj:"key"`
+		String string
+	}
+	type nested struct {
+		String string
+	}
+	type root struct {
+		Id     string `redisobj:"key"`
+		String string
+		Slice  []string
+		Map    map[string]int
+		Nested nested
+		Shared shared
+	}
+
+	objStore := redisobj.NewStore(redisClient)
+
+	object := &root{
+		Id:     "UUID",
+		String: "string",
+		Slice:  []string{"one", "two"},
+		Map:    map[string]int{"one": 1},
+		Nested: nested{String: "nested"},
+		Shared: shared{Id: "SHARED", String: "shared"},
+	}
+
+	keys := []string{
+		"{redisobj:root:UUID}",
+		"{redisobj:root:UUID}.__EXISTS__",
+		"{redisobj:root:UUID}.__HASH__",
+		"{redisobj:root:UUID}.Slice",
+		"{redisobj:root:UUID}.Map",
+		"{redisobj:root:UUID}:nested",
+	}
+
+	// Every key is given the same expiry, including sub-second expiries.
+	err := objStore.Write(ctx, object, redisobj.Options{EnableCaching: true, Ttl: 1500 * time.Millisecond})
+	assert.Nil(t, err)
+
+	for _, key := range keys {
+		actualTtl, err := redisClient.PTTL(key).Result()
+		assert.Nil(t, err)
+		assert.InDelta(t, 1500, actualTtl.Milliseconds(), 100, key)
+	}
+
+	for _, key := range append(keys[3:], "{redisobj:root:UUID}", "{redisobj:shared:SHARED}.__EXISTS__") {
+		err := objStore.Write(ctx, object, redisobj.Options{})
+		assert.Nil(t, err)
+
+		err = redisClient.Del(key).Err()
+		assert.Nil(t, err)
+
+		actualObject := &root{Id: "UUID", Shared: shared{Id: "SHARED"}}
+		err = objStore.Read(ctx, actualObject, redisobj.Options{})
+		assert.ErrorIs(t, err, redisobj.ErrPartialObject, key)
+		assert.ErrorIs(t, err, redisobj.ErrObjectNotFound, key)
+	}
+
+	// Objects without any keys left are not found rather than partial.
+	err = objStore.Delete(ctx, object, redisobj.Options{})
+	assert.Nil(t, err)
+
+	err = objStore.Read(ctx, &root{Id: "UUID", Shared: shared{Id: "SHARED"}}, redisobj.Options{})
+	assert.ErrorIs(t, err, redisobj.ErrObjectNotFound)
+	assert.False(t, errors.Is(err, redisobj.ErrPartialObject))
+}
+
+func Test_Store_added_fields(t *testing.T) {
+	redisClient := NewGoRedisClient()
+	redisClient.FlushAll()
+	ctx := context.Background()
+
+	type extra struct {
+		String string
+	}
+	type rootV1 struct {
+		_    struct{} `redisobj:"type=root"`
+		Id   string   `redisobj:"key"`
+		Name string
+	}
+	type rootV2 struct {
+		_     struct{} `redisobj:"type=root"`
+		Added int
+		Id    string `redisobj:"key"`
+		Name  string
+		Extra extra
+	}
+
+	err := redisobj.NewStore(redisClient).Write(ctx, &rootV1{Id: "UUID", Name: "name"}, redisobj.Options{})
+	assert.Nil(t, err)
+
+	// Fields and unkeyed nested structs added since the object was written are read as zero values.
+	objStore := redisobj.NewStore(redisClient)
+	for _, decode := range []redisobj.DecodeMode{redisobj.DecodeDefault, redisobj.DecodeLenient} {
+		actualObject := &rootV2{Id: "UUID", Added: 1, Extra: extra{String: "extra"}}
+		err = objStore.Read(ctx, actualObject, redisobj.Options{Decode: decode})
+		assert.Nil(t, err)
+		assert.Equal(t, &rootV2{Id: "UUID", Name: "name"}, actualObject)
+	}
+
+	actualObject := &rootV2{Id: "UUID"}
+	err = objStore.Read(ctx, actualObject, redisobj.Options{Decode: redisobj.DecodeStrict})
+	assert.ErrorIs(t, err, redisobj.ErrMissingField)
+	assert.False(t, errors.Is(err, redisobj.ErrPartialObject))
+
+	fieldErr := &redisobj.FieldError{}
+	assert.True(t, errors.As(err, &fieldErr))
+	assert.Equal(t, "Added", fieldErr.Field)
+
+	// Once written with the nested struct, its missing hash is a partial object.
+	err = objStore.Write(ctx, &rootV2{Id: "UUID", Name: "name"}, redisobj.Options{})
+	assert.Nil(t, err)
+	assert.Nil(t, redisClient.Del("{redisobj:root:UUID}:extra").Err())
+
+	err = objStore.Read(ctx, &rootV2{Id: "UUID"}, redisobj.Options{})
+	assert.ErrorIs(t, err, redisobj.ErrPartialObject)
+}
+
+func Test_Store_baseline_layout(t *testing.T) {
+	redisClient := NewGoRedisClient()
+	redisClient.FlushAll()
+	ctx := context.Background()
+
+	type nested struct {
+		Int  int
+		Tags []string
+	}
+	type root struct {
+		Id     string `redisobj:"key"`
+		String string
+		Slice  []string
+		Map    map[string]int
+		Nested nested
+	}
+
+	// Objects written before lengths were stored have no length field in their struct hashes.
+	pipe := redisClient.TxPipeline()
+	pipe.HSet("{redisobj:root:UUID}", "Id", "UUID", "String", "string")
+	pipe.ZAdd("{redisobj:root:UUID}.Slice", &redis.Z{Score: 0, Member: "one"}, &redis.Z{Score: 1, Member: "two"})
+	pipe.HSet("{redisobj:root:UUID}.Map", "one", "1")
+	pipe.HSet("{redisobj:root:UUID}:nested", "Int", "1")
+	pipe.ZAdd("{redisobj:root:UUID}:nested.Tags", &redis.Z{Score: 0, Member: "tag"})
+	pipe.Set("{redisobj:root:UUID}.__EXISTS__", "1", 0)
+	_, err := pipe.Exec()
+	assert.Nil(t, err)
+
+	object := &root{
+		Id:     "UUID",
+		String: "string",
+		Slice:  []string{"one", "two"},
+		Map:    map[string]int{"one": 1},
+		Nested: nested{Int: 1, Tags: []string{"tag"}},
+	}
+
+	objStore := redisobj.NewStore(redisClient)
+
+	actualObject := &root{Id: "UUID"}
+	err = objStore.Read(ctx, actualObject, redisobj.Options{})
+	assert.Nil(t, err)
+	assert.Equal(t, object, actualObject)
+
+	// Their collections are read without length checks, so keys expiring early go unnoticed.
+	assert.Nil(t, redisClient.Del("{redisobj:root:UUID}.Slice").Err())
+
+	actualObject = &root{Id: "UUID"}
+	err = objStore.Read(ctx, actualObject, redisobj.Options{})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{}, actualObject.Slice)
+
+	// Once written again, the lengths are stored and checked.
+	err = objStore.Write(ctx, object, redisobj.Options{})
+	assert.Nil(t, err)
+
+	lengths, err := redisClient.HGet("{redisobj:root:UUID}", "__LEN__").Result()
+	assert.Nil(t, err)
+	assert.Equal(t, "Slice=2,Map=1,Nested=1", lengths)
+
+	assert.Nil(t, redisClient.Del("{redisobj:root:UUID}.Slice").Err())
+
+	err = objStore.Read(ctx, &root{Id: "UUID"}, redisobj.Options{})
+	assert.ErrorIs(t, err, redisobj.ErrPartialObject)
+}
+
+func Test_Store_empty_collections(t *testing.T) {
+	redisClient := NewGoRedisClient()
+	redisClient.FlushAll()
+	ctx := context.Background()
+
+	type nested struct {
+		Slice []int
+	}
+	type root struct {
+		Id     string `redisobj:"key"`
+		Slice  []string
+		Map    map[string]int
+		Nested nested
+	}
+
+	objStore := redisobj.NewStore(redisClient)
+
+	for _, options := range []redisobj.Options{{}, {EnableCaching: true}} {
+		object := &root{
+			Id:     "UUID",
+			Slice:  []string{"one", "two"},
+			Map:    map[string]int{"one": 1},
+			Nested: nested{Slice: []int{1}},
+		}
+		err := objStore.Write(ctx, object, options)
+		assert.Nil(t, err)
+
+		// Emptied collections are removed and read back as empty.
+		object = &root{
+			Id:     "UUID",
+			Slice:  []string{},
+			Map:    map[string]int{},
+			Nested: nested{Slice: []int{}},
+		}
+		err = objStore.Write(ctx, object, options)
+		assert.Nil(t, err)
+
+		exists, err := redisClient.Exists("{redisobj:root:UUID}.Slice", "{redisobj:root:UUID}.Map", "{redisobj:root:UUID}:nested.Slice").Result()
+		assert.Nil(t, err)
+		assert.Equal(t, int64(0), exists)
+
+		actualObject := &root{Id: "UUID"}
+		err = objStore.Read(ctx, actualObject, redisobj.Options{})
+		assert.Nil(t, err)
+		assert.Equal(t, object, actualObject)
+
+		// Nil collections are read back as nil.
+		object = &root{Id: "UUID"}
+		err = objStore.Write(ctx, object, options)
+		assert.Nil(t, err)
+
+		actualObject = &root{Id: "UUID", Slice: []string{}, Map: map[string]int{}}
+		err = objStore.Read(ctx, actualObject, redisobj.Options{})
+		assert.Nil(t, err)
+		assert.Equal(t, object, actualObject)
+	}
+
+	// Objects written before lengths were stored read their missing collections as empty, as they always have.
+	redisClient.FlushAll()
+	pipe := redisClient.TxPipeline()
+	pipe.HSet("{redisobj:root:UUID}", "Id", "UUID")
+	pipe.Set("{redisobj:root:UUID}.__EXISTS__", "1", 0)
+	_, err := pipe.Exec()
+	assert.Nil(t, err)
+
+	actualObject := &root{Id: "UUID"}
+	err = objStore.Read(ctx, actualObject, redisobj.Options{})
+	assert.Nil(t, err)
+	assert.Equal(t, &root{Id: "UUID", Slice: []string{}, Map: map[string]int{}, Nested: nested{Slice: []int{}}}, actualObject)
+}
+
+func Test_Store_field_errors(t *testing.T) {
+	redisClient := NewGoRedisClient()
+	redisClient.FlushAll()
+	ctx := context.Background()
+
+	type nested struct {
+		Int int64
+	}
+	type root struct {
+		Id     string `redisobj:"key"`
+		String string
+		Bool   bool
+		Slice  []int
+		Map    map[string]float64
+		Nested nested
+	}
+
+	objStore := redisobj.NewStore(redisClient)
+
+	object := &root{
+		Id:     "UUID",
+		String: "string",
+		Slice:  []int{1, 2},
+		Map:    map[string]float64{"one": 1},
+		Nested: nested{Int: 1},
+	}
+	err := objStore.Write(ctx, object, redisobj.Options{})
+	assert.Nil(t, err)
+
+	assert.Nil(t, redisClient.HSet("{redisobj:root:UUID}", "Bool", "maybe").Err())
+	assert.Nil(t, redisClient.ZAdd("{redisobj:root:UUID}.Slice", &redis.Z{Score: 1, Member: "two"}).Err())
+	assert.Nil(t, redisClient.ZRem("{redisobj:root:UUID}.Slice", "2").Err())
+	assert.Nil(t, redisClient.HSet("{redisobj:root:UUID}.Map", "one", "abc").Err())
+	assert.Nil(t, redisClient.HSet("{redisobj:root:UUID}:nested", "Int", "abc").Err())
+
+	actualObject := &root{Id: "UUID"}
+	err = objStore.Read(ctx, actualObject, redisobj.Options{})
+	assert.ErrorIs(t, err, redisobj.ErrInvalidFieldType)
+
+	// Every failing field is reported, and the other fields are still decoded.
+	assert.Len(t, err, 4)
+	assert.Equal(t, "string", actualObject.String)
+
+	fieldErrs := map[string]*redisobj.FieldError{}
+	for _, err := range err.(redisobj.MultiError) {
+		fieldErr := &redisobj.FieldError{}
+		assert.True(t, errors.As(err, &fieldErr))
+		fieldErrs[fieldErr.Path] = fieldErr
+	}
+
+	assert.Equal(t, &redisobj.FieldError{
+		Type:  reflect.TypeOf(root{}),
+		Path:  "root.Bool",
+		Key:   "{redisobj:root:UUID}",
+		Field: "Bool",
+		Value: "maybe",
+		Err:   fieldErrs["root.Bool"].Err,
+	}, fieldErrs["root.Bool"])
+	assert.Equal(t, &redisobj.FieldError{
+		Type:  reflect.TypeOf(root{}),
+		Path:  "root.Slice",
+		Key:   "{redisobj:root:UUID}.Slice",
+		Field: "1",
+		Value: "two",
+		Err:   fieldErrs["root.Slice"].Err,
+	}, fieldErrs["root.Slice"])
+	assert.Equal(t, &redisobj.FieldError{
+		Type:  reflect.TypeOf(root{}),
+		Path:  "root.Map",
+		Key:   "{redisobj:root:UUID}.Map",
+		Field: "one",
+		Value: "abc",
+		Err:   fieldErrs["root.Map"].Err,
+	}, fieldErrs["root.Map"])
+	assert.Equal(t, &redisobj.FieldError{
+		Type:  reflect.TypeOf(nested{}),
+		Path:  "root.Nested.Int",
+		Key:   "{redisobj:root:UUID}:nested",
+		Field: "Int",
+		Value: "abc",
+		Err:   fieldErrs["root.Nested.Int"].Err,
+	}, fieldErrs["root.Nested.Int"])
+	assert.ErrorIs(t, fieldErrs["root.Nested.Int"], redisobj.ErrInvalidFieldType)
+	assert.Equal(t, `invalid field type: could not parse value (int64) from string (abc): root.Nested.Int (key {redisobj:root:UUID}:nested, field Int, value "abc")`, fieldErrs["root.Nested.Int"].Error())
+}
+
+func Test_Store_decode_modes(t *testing.T) {
+	redisClient := NewGoRedisClient()
+	redisClient.FlushAll()
+	ctx := context.Background()
+
+	type root struct {
+		Id     string `redisobj:"key"`
+		String string
+		Int    int
+		Bool   bool
+		Slice  []int
+	}
+
+	objStore := redisobj.NewStore(redisClient)
+
+	object := &root{Id: "UUID", String: "string", Int: 1, Bool: true, Slice: []int{1, 2}}
+	err := objStore.Write(ctx, object, redisobj.Options{})
+	assert.Nil(t, err)
+
+	// The stored object matches the struct, including its metadata fields.
+	actualObject := &root{Id: "UUID"}
+	err = objStore.Read(ctx, actualObject, redisobj.Options{Decode: redisobj.DecodeStrict})
+	assert.Nil(t, err)
+	assert.Equal(t, object, actualObject)
+
+	// An older version of the struct had other fields.
+	assert.Nil(t, redisClient.HDel("{redisobj:root:UUID}", "Bool").Err())
+	assert.Nil(t, redisClient.HSet("{redisobj:root:UUID}", "Removed", "value", "Int", "abc").Err())
+
+	actualObject = &root{Id: "UUID"}
+	err = objStore.Read(ctx, actualObject, redisobj.Options{})
+	assert.ErrorIs(t, err, redisobj.ErrInvalidFieldType)
+	assert.Len(t, err, 1)
+
+	actualObject = &root{Id: "UUID"}
+	err = objStore.Read(ctx, actualObject, redisobj.Options{Decode: redisobj.DecodeStrict})
+	assert.ErrorIs(t, err, redisobj.ErrInvalidFieldType)
+	assert.ErrorIs(t, err, redisobj.ErrMissingField)
+	assert.ErrorIs(t, err, redisobj.ErrUnknownField)
+	assert.Len(t, err, 3)
+
+	fieldErr := &redisobj.FieldError{}
+	for _, err := range err.(redisobj.MultiError) {
+		if errors.Is(err, redisobj.ErrUnknownField) {
+			assert.True(t, errors.As(err, &fieldErr))
+		}
+	}
+	assert.Equal(t, "root.Removed", fieldErr.Path)
+	assert.Equal(t, "Removed", fieldErr.Field)
+
+	var skipped []string
+	actualObject = &root{Id: "UUID"}
+	err = objStore.Read(ctx, actualObject, redisobj.Options{
+		Decode: redisobj.DecodeLenient,
+		OnDecodeError: func(err *redisobj.FieldError) {
+			skipped = append(skipped, err.Path)
+		},
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"root.Int"}, skipped)
+	assert.Equal(t, &root{Id: "UUID", String: "string", Slice: []int{1, 2}}, actualObject)
+}
+
+func Test_Store_numeric_fidelity(t *testing.T) {
+	redisClient := NewGoRedisClient()
+	redisClient.FlushAll()
+	ctx := context.Background()
+
+	type level int16
+	type numbers struct {
+		Id       level `redisobj:"key"`
+		Int      int
+		Int8     int8
+		Int16    int16
+		Int32    int32
+		Int64    int64
+		Uint     uint
+		Uint8    uint8
+		Uint16   uint16
+		Uint32   uint32
+		Uint64   uint64
+		Float32  float32
+		Float64  float64
+		Inf      float64
+		NegInf   float32
+		Floats   []float64
+		Extremes map[int64]uint64
+	}
+
+	objStore := redisobj.NewStore(redisClient)
+
+	for _, object := range []*numbers{
+		{
+			Id:       math.MaxInt16,
+			Int:      math.MaxInt,
+			Int8:     math.MaxInt8,
+			Int16:    math.MaxInt16,
+			Int32:    math.MaxInt32,
+			Int64:    math.MaxInt64,
+			Uint:     math.MaxUint,
+			Uint8:    math.MaxUint8,
+			Uint16:   math.MaxUint16,
+			Uint32:   math.MaxUint32,
+			Uint64:   math.MaxUint64,
+			Float32:  math.MaxFloat32,
+			Float64:  math.MaxFloat64,
+			Inf:      math.Inf(1),
+			NegInf:   float32(math.Inf(-1)),
+			Floats:   []float64{math.SmallestNonzeroFloat64, 0.1, -0.3},
+			Extremes: map[int64]uint64{math.MaxInt64: math.MaxUint64},
+		},
+		{
+			Id:       math.MinInt16,
+			Int:      math.MinInt,
+			Int8:     math.MinInt8,
+			Int16:    math.MinInt16,
+			Int32:    math.MinInt32,
+			Int64:    math.MinInt64,
+			Float32:  math.SmallestNonzeroFloat32,
+			Float64:  -math.MaxFloat64,
+			Extremes: map[int64]uint64{math.MinInt64: 0},
+		},
+	} {
+		err := objStore.Write(ctx, object, redisobj.Options{})
+		assert.Nil(t, err)
+
+		actualObject := &numbers{Id: object.Id}
+		err = objStore.Read(ctx, actualObject, redisobj.Options{})
+		assert.Nil(t, err)
+		assert.Equal(t, object, actualObject)
+	}
+
+	// NaN does not equal itself, so it is compared separately.
+	err := objStore.Write(ctx, &numbers{Id: 1, Float64: math.NaN(), Floats: []float64{math.NaN()}}, redisobj.Options{})
+	assert.Nil(t, err)
+
+	actualObject := &numbers{Id: 1}
+	err = objStore.Read(ctx, actualObject, redisobj.Options{})
+	assert.Nil(t, err)
+	assert.True(t, math.IsNaN(actualObject.Float64))
+	assert.True(t, math.IsNaN(actualObject.Floats[0]))
+
+	// Values out of the range of their field fail with an overflow error.
+	for field, value := range map[string]string{
+		"Int8":    "128",
+		"Uint16":  "65536",
+		"Int64":   "9223372036854775808",
+		"Uint":    "-1",
+		"Float32": "1e39",
+	} {
+		assert.Nil(t, redisClient.HSet("{redisobj:numbers:1}", field, value).Err())
+
+		err = objStore.Read(ctx, &numbers{Id: 1}, redisobj.Options{})
+		assert.ErrorIs(t, err, redisobj.ErrInvalidFieldType, field)
+		if field != "Uint" {
+			assert.ErrorIs(t, err, redisobj.ErrValueOverflow, field)
+		}
+
+		assert.Nil(t, redisClient.HSet("{redisobj:numbers:1}", field, "0").Err())
+	}
+}
+
+func Test_Store_bytes(t *testing.T) {
+	redisClient := NewGoRedisClient()
+	redisClient.FlushAll()
+	ctx := context.Background()
+
+	type root struct {
+		Id    string `redisobj:"key"`
+		Bytes []byte
+		Array [4]byte
+		Blob  []byte `redisobj:"blob"`
+	}
+
+	objStore := redisobj.NewStore(redisClient)
+
+	for _, options := range []redisobj.Options{{}, {EnableCaching: true}} {
+		object := &root{
+			Id:    "UUID",
+			Bytes: []byte("\x00\r\n\x00\xff\xff"),
+			Array: [4]byte{0, 1, 1, 255},
+			Blob:  []byte("large\x00binary\r\nvalue"),
+		}
+		err := objStore.Write(ctx, object, options)
+		assert.Nil(t, err)
+
+		// Byte slices are stored as single values, and blobs under keys of their own.
+		value, err := redisClient.HGet("{redisobj:root:UUID}", "Bytes").Result()
+		assert.Nil(t, err)
+		assert.Equal(t, "\x00\r\n\x00\xff\xff", value)
+
+		value, err = redisClient.Get("{redisobj:root:UUID}.Blob").Result()
+		assert.Nil(t, err)
+		assert.Equal(t, "large\x00binary\r\nvalue", value)
+
+		actualObject := &root{Id: "UUID"}
+		err = objStore.Read(ctx, actualObject, redisobj.Options{})
+		assert.Nil(t, err)
+		assert.Equal(t, object, actualObject)
+
+		// Empty blobs are read back as empty, and nil blobs as nil.
+		object = &root{Id: "UUID", Blob: []byte{}}
+		err = objStore.Write(ctx, object, options)
+		assert.Nil(t, err)
+
+		actualObject = &root{Id: "UUID"}
+		err = objStore.Read(ctx, actualObject, redisobj.Options{})
+		assert.Nil(t, err)
+		assert.Equal(t, object, actualObject)
+
+		object = &root{Id: "UUID"}
+		err = objStore.Write(ctx, object, options)
+		assert.Nil(t, err)
+
+		actualObject = &root{Id: "UUID", Blob: []byte{}}
+		err = objStore.Read(ctx, actualObject, redisobj.Options{})
+		assert.Nil(t, err)
+		assert.Equal(t, object, actualObject)
+	}
+
+	// Array values of the wrong length fail to decode.
+	err := redisClient.HSet("{redisobj:root:UUID}", "Array", "abc").Err()
+	assert.Nil(t, err)
+
+	err = objStore.Read(ctx, &root{Id: "UUID"}, redisobj.Options{})
+	assert.True(t, errors.Is(err, redisobj.ErrInvalidFieldType))
+
+	// Blob options are only valid on byte slices and arrays.
+	type invalid struct {
+		Id   string `redisobj:"key"`
+		Blob string `redisobj:"blob"`
+	}
+	err = objStore.Write(ctx, &invalid{Id: "UUID"}, redisobj.Options{})
+	assert.True(t, errors.Is(err, redisobj.ErrInvalidRedisDefinition))
+}
+
+func Test_Store_arrays(t *testing.T) {
+	redisClient := NewGoRedisClient()
+	redisClient.FlushAll()
+	ctx := context.Background()
+
+	type root struct {
+		Id          string `redisobj:"key"`
+		Coordinates [3]float64
+		Hash        [16]byte
+		Empty       [0]int
+	}
+
+	objStore := redisobj.NewStore(redisClient)
+
+	for _, options := range []redisobj.Options{{}, {EnableCaching: true}} {
+		object := &root{
+			Id:          "UUID",
+			Coordinates: [3]float64{1.5, -2, 1.5},
+			Hash:        [16]byte{0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 255},
+		}
+		err := objStore.Write(ctx, object, options)
+		assert.Nil(t, err)
+
+		// Arrays are stored as lists, and byte arrays as single values.
+		values, err := redisClient.LRange("{redisobj:root:UUID}.Coordinates", 0, -1).Result()
+		assert.Nil(t, err)
+		assert.Equal(t, []string{"1.5", "-2", "1.5"}, values)
+
+		actualObject := &root{Id: "UUID"}
+		err = objStore.Read(ctx, actualObject, redisobj.Options{})
+		assert.Nil(t, err)
+		assert.Equal(t, object, actualObject)
+	}
+
+	// Arrays read from a different number of elements fail to decode.
+	pipe := redisClient.TxPipeline()
+	pipe.RPop("{redisobj:root:UUID}.Coordinates")
+	pipe.HSet("{redisobj:root:UUID}", "__LEN__", "Coordinates=2")
+	_, err := pipe.Exec()
+	assert.Nil(t, err)
+
+	err = objStore.Read(ctx, &root{Id: "UUID"}, redisobj.Options{})
+	assert.True(t, errors.Is(err, redisobj.ErrInvalidFieldType))
+
+	var fieldErr *redisobj.FieldError
+	assert.True(t, errors.As(err, &fieldErr))
+	assert.Equal(t, "root.Coordinates", fieldErr.Path)
+}
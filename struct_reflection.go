@@ -1,29 +1,82 @@
 package redisobj
 
 import (
-	"context"
 	"fmt"
 	"reflect"
 	"strconv"
-	"strings"
 	"time"
 
 	"github.com/go-redis/redis/v7"
-	"github.com/mitchellh/hashstructure/v2"
 )
 
+// slideTtlScript expires all keys of an object if its existence marker, the second key, records it as existing.
+var slideTtlScript = redis.NewScript(`
+if redis.call("get", KEYS[2]) ~= ARGV[1] then
+	return 0
+end
+for index = 1, #KEYS do
+	redis.call("pexpire", KEYS[index], ARGV[2])
+end
+return 1
+`)
+
+// keepTtlScript expires the keys of an object when its existence marker, the first key, expires.
+// Rewritten keys lose their expiry, while the marker keeps it when it is set with KEEPTTL.
+var keepTtlScript = redis.NewScript(`
+local ttl = redis.call("pttl", KEYS[1])
+if ttl <= 0 then
+	return 0
+end
+for index = 2, #KEYS do
+	redis.call("pexpire", KEYS[index], ttl)
+end
+return 1
+`)
+
+// shortenTtlScript expires the key within ARGV[1] milliseconds, unless it expires sooner.
+var shortenTtlScript = redis.NewScript(`
+local ttl = redis.call("pttl", KEYS[1])
+if ttl == -2 or (ttl ~= -1 and ttl <= tonumber(ARGV[1])) then
+	return 0
+end
+redis.call("pexpire", KEYS[1], ARGV[1])
+return 1
+`)
+
 const (
 	// structTagRedisobj defines the struct tag key for all redisobj struct tag options.
 	structTagKeyRedisobj = "redisobj"
 	structTagValueKey    = "key"
+
+	// existenceMarkerExists and existenceMarkerMissing are the values of the .__EXISTS__ key.
+	existenceMarkerExists  = "1"
+	existenceMarkerMissing = "0"
+
+	// lengthField is the struct hash field holding the lengths of the non-nil slice, map and blob fields and the field
+	// counts of unkeyed nested structs, as comma separated Name=length pairs.
+	lengthField = "__LEN__"
 )
 
 type reflectionData struct {
-	objType      reflect.Type
-	objName      string
-	structIndex  int
-	redisWriteFn func(pipe redis.Pipeliner, keyPrefix string, objValue reflect.Value, ttl time.Duration) error
-	redisReadFn  func(pipe redis.Pipeliner, keyPrefix string, objValue reflect.Value) readResultsCallback
+	objType     reflect.Type
+	objName     string
+	structIndex int
+	isKey       bool
+	plan        *FieldPlan
+	// path is the dotted path of the field, starting at the name of the root struct.
+	path string
+	// nameArg is the field name boxed once as a command argument, avoiding an allocation per write.
+	nameArg interface{}
+	// keySuffix is appended to the struct key to form the key of a slice or map field.
+	keySuffix string
+	// lengthName is the name of the length of a slice, map or blob field, or of the field count of an unkeyed nested
+	// struct, in the length field of the struct containing it.
+	lengthName string
+	// lengthIndex is the index of the length field in the HMGET arguments of the struct containing the field, or -1 if
+	// the length is not stored.
+	lengthIndex int
+	// ttl is the expiry of the key of a slice or map field tagged with `redisobj:"ttl=..."`.
+	ttl time.Duration
 }
 
 // objStruct defines the reflection parameters of the object type.
@@ -32,360 +85,344 @@ type objStruct struct {
 	structData    reflectionData
 	keyFieldIndex int
 	valueFields   []*reflectionData
-	sliceFields   []*reflectionData
-	mapFields     []*reflectionData
-	structFields  []*objStruct
-	fieldCount    int
+	// readFieldArgs are the boxed value field names followed by the length field, used as HMGET arguments.
+	readFieldArgs []interface{}
+	// hasLengths is set if the struct hash holds the length field.
+	hasLengths bool
+	// sliceFields are the slice and array fields of the struct.
+	sliceFields []*reflectionData
+	mapFields   []*reflectionData
+	// blobFields are the byte slice and array fields tagged with `redisobj:"blob"`, stored under keys of their own.
+	blobFields   []*reflectionData
+	structFields []*objStruct
+	fieldCount   int
+	// hasPlans is set if any field of this struct or its nested structs uses a generated plan.
+	hasPlans bool
+	// fieldTtl is the shortest ttl tag of the slice and map fields stored with this struct, or 0.
+	fieldTtl time.Duration
 }
 
-func newObjStruct(obj interface{}) (*objStruct, error) {
-	objType := reflect.TypeOf(obj)
-	objValue := reflect.ValueOf(obj)
-	if objType.Kind() == reflect.Ptr {
-		objType = objType.Elem()
-		objValue = objValue.Elem()
+// newObjStruct parses the struct type into an objStruct stored under objName.
+// If objName is empty, the name is resolved from the type aliases, struct tags, or the type name.
+// Every invalid field is reported in a MultiError, identified by its dotted path starting at fieldPath.
+func newObjStruct(objType reflect.Type, objName string, fieldPath string, typeAliases map[reflect.Type]string) (*objStruct, error) {
+	if objName == "" {
+		var err error
+		if objName, err = structTypeName(objType, typeAliases); err != nil {
+			return nil, MultiError{err}
+		}
 	}
+	if fieldPath == "" {
+		fieldPath = objName
+	}
+
+	errs := MultiError{}
 
 	objStructRef := &objStruct{
 		structData: reflectionData{
 			objType:     objType,
-			objName:     objType.Name(),
+			objName:     objName,
 			structIndex: -1,
+			path:        fieldPath,
+			lengthIndex: -1,
 		},
 		keyFieldIndex: -1,
 		valueFields:   []*reflectionData{},
+		readFieldArgs: []interface{}{},
 		sliceFields:   []*reflectionData{},
 		mapFields:     []*reflectionData{},
+		blobFields:    []*reflectionData{},
 		structFields:  []*objStruct{},
 		fieldCount:    0,
 	}
 
 	// Iterate over all available fields and read the tag value
 	for structFieldIndex := 0; structFieldIndex < objType.NumField(); structFieldIndex++ {
-		fieldValue := objValue.Field(structFieldIndex)
 		fieldType := objType.Field(structFieldIndex)
+		if fieldType.PkgPath != "" {
+			// Blank fields only carry struct tag options for the struct itself.
+			// Other unexported fields cannot be accessed through reflection.
+			continue
+		}
 
-		switch fieldType.Type.Kind() {
-		case reflect.Struct:
-			// Recurse over embedded structs.
-			structField, err := newObjStruct(fieldValue.Interface())
-			if err != nil {
-				return nil, err
-			}
-			structField.structData.structIndex = structFieldIndex
-			objStructRef.structFields = append(objStructRef.structFields, structField)
-
-			objStructRef.fieldCount += structField.fieldCount
+		structFieldPath := fieldPath + "." + fieldType.Name
+		tagOptions, err := parseStructTag(fieldType.Tag)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%w: %s", err, structFieldPath))
+			continue
+		}
 
-		case reflect.Slice:
-			// TODO: This could probably support struct values with a bit more effort.
-			if !isStringParsable(fieldType.Type.Elem()) {
-				return nil, fmt.Errorf("%w: %s", ErrInvalidFieldType, "slice values must be a primitive type that is string parsable with strconv")
-			}
-			data := &reflectionData{
-				objType:     fieldType.Type,
-				objName:     fieldType.Name,
-				structIndex: structFieldIndex,
-			}
-			data.redisWriteFn = func(pipe redis.Pipeliner, keyPrefix string, objValue reflect.Value, ttl time.Duration) error {
-				key := keyPrefix + "." + data.objName
-				sliceField := objValue.Field(data.structIndex)
+		isBytes := isByteSequence(fieldType.Type)
 
-				if sliceField.Len() == 0 {
-					return nil
-				}
+		if tagOptions.isBlob && !isBytes {
+			errs = append(errs, fmt.Errorf("%w: %s: blob options are only supported on byte slice and byte array fields", ErrInvalidRedisDefinition, structFieldPath))
+			continue
+		}
 
-				valueSlice := make([]*redis.Z, sliceField.Len())
+		if tagOptions.ttl != 0 && (isBytes || !isCollectionKind(fieldType.Type.Kind())) && !tagOptions.isBlob {
+			errs = append(errs, fmt.Errorf("%w: %s: ttl options are only supported on slice, array, map and blob fields", ErrInvalidRedisDefinition, structFieldPath))
+			continue
+		}
 
-				for i := 0; i < sliceField.Len(); i++ {
-					value := sliceField.Index(i)
+		if tagOptions.isKey && !isStringParsable(fieldType.Type) {
+			errs = append(errs, fmt.Errorf("%w: %s: key fields must be a primitive type that is string parsable with strconv", ErrInvalidFieldType, structFieldPath))
+			continue
+		}
 
-					valueString, err := valueToString(value)
-					if err != nil {
-						return err
-					}
-					valueSlice[i] = &redis.Z{
-						Score:  float64(i),
-						Member: valueString,
-					}
-				}
+		data := &reflectionData{
+			objType:     fieldType.Type,
+			objName:     fieldType.Name,
+			structIndex: structFieldIndex,
+			nameArg:     fieldType.Name,
+			keySuffix:   "." + fieldType.Name,
+			path:        structFieldPath,
+			lengthIndex: -1,
+			ttl:         tagOptions.ttl,
+		}
 
-				pipe.Del(key)
-				pipe.ZAdd(key, valueSlice...)
+		switch {
+		case isBytes:
+			// Byte slices and arrays are stored as a single binary safe value, in the struct hash or under a key of their own.
+			data.plan = lookupFieldPlan(objType, fieldType.Name, hasValuePlan)
+
+			if tagOptions.isBlob {
+				objStructRef.blobFields = append(objStructRef.blobFields, data)
+				objStructRef.fieldTtl = minFieldTtl(objStructRef.fieldTtl, data.ttl)
+			} else {
+				objStructRef.valueFields = append(objStructRef.valueFields, data)
+				objStructRef.readFieldArgs = append(objStructRef.readFieldArgs, data.nameArg)
+			}
+			objStructRef.fieldCount++
+			objStructRef.hasPlans = objStructRef.hasPlans || data.plan != nil
 
-				if ttl != 0 {
-					pipe.Expire(key, ttl)
+		case fieldType.Type.Kind() == reflect.Struct:
+			if tagOptions.typeName != "" {
+				if err := validateTypeName(tagOptions.typeName); err != nil {
+					errs = append(errs, fmt.Errorf("%w: %s", err, structFieldPath))
+					continue
 				}
-
-				return nil
 			}
-			data.redisReadFn = func(pipe redis.Pipeliner, keyPrefix string, objValue reflect.Value) readResultsCallback {
-				key := keyPrefix + "." + data.objName
-
-				pipe.ZRange(key, 0, -1)
-
-				return func(result redis.Cmder) error {
-					redisValue, err := result.(*redis.StringSliceCmd).Result()
-					if err != nil {
-						if err == redis.Nil {
-							redisValue = nil
-						} else {
-							return fmt.Errorf("%w Get: %s", ErrRedisCommandError, err)
-						}
-					}
-
-					sliceField := objValue.Field(data.structIndex)
-					sliceField.Set(reflect.MakeSlice(data.objType, len(redisValue), len(redisValue)))
-					for index, readValue := range redisValue {
-						value := reflect.New(data.objType.Elem()).Elem()
-						if err := setFieldFromString(value, readValue); err != nil {
-							return err
-						}
-
-						sliceIndex := sliceField.Index(index)
-						sliceIndex.Set(value)
-					}
-
-					return nil
+
+			// Unkeyed structs are stored with their parent, so anonymous struct types fall back to the field name.
+			nestedName := tagOptions.typeName
+			isAnonymous := false
+			if nestedName == "" && fieldType.Type.Name() == "" {
+				if nestedName, err = declaredTypeName(fieldType.Type, typeAliases); err != nil {
+					errs = append(errs, fmt.Errorf("%w: %s", err, structFieldPath))
+					continue
+				}
+				if nestedName == "" {
+					nestedName = fieldType.Name
+					isAnonymous = true
+				} else if err := validateTypeName(nestedName); err != nil {
+					errs = append(errs, fmt.Errorf("%w: %s", err, structFieldPath))
+					continue
 				}
 			}
 
-			objStructRef.sliceFields = append(objStructRef.sliceFields, data)
-			objStructRef.fieldCount++
+			// Recurse over embedded structs.
+			structField, err := newObjStruct(fieldType.Type, nestedName, structFieldPath, typeAliases)
+			if err != nil {
+				errs = append(errs, err.(MultiError)...)
+				continue
+			}
+			if isAnonymous && structField.keyFieldIndex != -1 {
+				errs = append(errs, fmt.Errorf("%w: %s: anonymous keyed struct types require a type name", ErrInvalidObject, structFieldPath))
+				continue
+			}
+			structField.structData.structIndex = structFieldIndex
+			if structField.keyFieldIndex == -1 && len(structField.valueFields) != 0 {
+				// The field count of unkeyed structs is stored in the struct hash, so that reads notice their hash expiring early.
+				structField.structData.lengthName = fieldType.Name
+			}
+			objStructRef.structFields = append(objStructRef.structFields, structField)
 
-		case reflect.Map:
-			if !isStringParsable(fieldType.Type.Key()) {
-				return nil, fmt.Errorf("%w: %s", ErrInvalidFieldType, "map keys must be a primitive type that is string parsable with strconv")
+			objStructRef.fieldCount += structField.fieldCount
+			objStructRef.hasPlans = objStructRef.hasPlans || structField.hasPlans
+			if structField.keyFieldIndex == -1 {
+				objStructRef.fieldTtl = minFieldTtl(objStructRef.fieldTtl, structField.fieldTtl)
 			}
 
+		case fieldType.Type.Kind() == reflect.Slice || fieldType.Type.Kind() == reflect.Array:
+			// Arrays are stored under a key of their own like slices, and must be read back with as many elements as the array holds.
 			// TODO: This could probably support struct values with a bit more effort.
 			if !isStringParsable(fieldType.Type.Elem()) {
-				return nil, fmt.Errorf("%w: %s", ErrInvalidFieldType, "map values must be a primitive type that is string parsable with strconv")
-			}
-			data := &reflectionData{
-				objType:     fieldType.Type,
-				objName:     fieldType.Name,
-				structIndex: structFieldIndex,
+				errs = append(errs, fmt.Errorf("%w: %s: slice and array values must be a primitive type that is string parsable with strconv", ErrInvalidFieldType, structFieldPath))
+				continue
 			}
-			data.redisWriteFn = func(pipe redis.Pipeliner, keyPrefix string, objValue reflect.Value, ttl time.Duration) error {
-				key := keyPrefix + "." + data.objName
-				mapField := objValue.Field(data.structIndex)
+			data.plan = lookupFieldPlan(objType, fieldType.Name, hasSlicePlan)
 
-				if mapField.Len() == 0 {
-					return nil
-				}
-
-				valueMap := make(map[string]interface{}, mapField.Len())
-
-				iter := mapField.MapRange()
-				for iter.Next() {
-					key := iter.Key()
-					value := iter.Value()
-
-					keyString, err := valueToString(key)
-					if err != nil {
-						return err
-					}
-					valueString, err := valueToString(value)
-					if err != nil {
-						return err
-					}
-					valueMap[keyString] = valueString
-				}
-
-				pipe.Del(key)
-				pipe.HSet(key, valueMap)
-
-				if ttl != 0 {
-					pipe.Expire(key, ttl)
-				}
+			objStructRef.sliceFields = append(objStructRef.sliceFields, data)
+			objStructRef.fieldCount++
+			objStructRef.fieldTtl = minFieldTtl(objStructRef.fieldTtl, data.ttl)
+			objStructRef.hasPlans = objStructRef.hasPlans || data.plan != nil
 
-				return nil
+		case fieldType.Type.Kind() == reflect.Map:
+			if !isStringParsable(fieldType.Type.Key()) {
+				errs = append(errs, fmt.Errorf("%w: %s: map keys must be a primitive type that is string parsable with strconv", ErrInvalidFieldType, structFieldPath))
+				continue
 			}
-			data.redisReadFn = func(pipe redis.Pipeliner, keyPrefix string, objValue reflect.Value) readResultsCallback {
-				key := keyPrefix + "." + data.objName
-
-				pipe.HGetAll(key)
-
-				return func(result redis.Cmder) error {
-					redisValue, err := result.(*redis.StringStringMapCmd).Result()
-					if err != nil {
-						if err == redis.Nil {
-							redisValue = nil
-						} else {
-							return fmt.Errorf("%w Get: %s", ErrRedisCommandError, err)
-						}
-					}
-
-					mapField := objValue.Field(data.structIndex)
-					mapField.Set(reflect.MakeMap(data.objType))
-
-					for readKey, readValue := range redisValue {
-						keyValue := reflect.New(data.objType.Key()).Elem()
-						if err := setFieldFromString(keyValue, readKey); err != nil {
-							return err
-						}
-
-						valueValue := reflect.New(data.objType.Elem()).Elem()
-						if err := setFieldFromString(valueValue, readValue); err != nil {
-							return err
-						}
-
-						mapField.SetMapIndex(keyValue, valueValue)
-					}
-
-					return nil
-				}
+
+			// TODO: This could probably support struct values with a bit more effort.
+			if !isStringParsable(fieldType.Type.Elem()) {
+				errs = append(errs, fmt.Errorf("%w: %s: map values must be a primitive type that is string parsable with strconv", ErrInvalidFieldType, structFieldPath))
+				continue
 			}
+			data.plan = lookupFieldPlan(objType, fieldType.Name, hasMapPlan)
 
 			objStructRef.mapFields = append(objStructRef.mapFields, data)
 			objStructRef.fieldCount++
+			objStructRef.fieldTtl = minFieldTtl(objStructRef.fieldTtl, data.ttl)
+			objStructRef.hasPlans = objStructRef.hasPlans || data.plan != nil
+
 		default:
-			if tagValue, exists := fieldType.Tag.Lookup(structTagKeyRedisobj); exists {
-				if strings.EqualFold(tagValue, structTagValueKey) {
-					objStructRef.keyFieldIndex = structFieldIndex
-				}
+			if !isStringParsable(fieldType.Type) {
+				errs = append(errs, fmt.Errorf("%w: %s: %s fields are not supported", ErrInvalidFieldType, structFieldPath, fieldType.Type.Kind()))
+				continue
 			}
 
-			data := &reflectionData{
-				objType:     fieldType.Type,
-				objName:     fieldType.Name,
-				structIndex: structFieldIndex,
+			if tagOptions.isKey {
+				objStructRef.keyFieldIndex = structFieldIndex
 			}
-			data.redisWriteFn = func(pipe redis.Pipeliner, keyPrefix string, objValue reflect.Value, ttl time.Duration) error {
-				key := keyPrefix
-				value := objValue.Field(data.structIndex).Interface()
+			data.isKey = tagOptions.isKey
+			data.plan = lookupFieldPlan(objType, fieldType.Name, hasValuePlan)
 
-				pipe.HSet(key, data.objName, value)
+			objStructRef.valueFields = append(objStructRef.valueFields, data)
+			objStructRef.readFieldArgs = append(objStructRef.readFieldArgs, data.nameArg)
+			objStructRef.fieldCount++
+			objStructRef.hasPlans = objStructRef.hasPlans || data.plan != nil
+		}
+	}
 
-				if ttl != 0 {
-					pipe.Expire(key, ttl)
-				}
+	if len(errs) != 0 {
+		return nil, errs
+	}
 
-				return nil
-			}
-			data.redisReadFn = func(pipe redis.Pipeliner, keyPrefix string, objValue reflect.Value) readResultsCallback {
-				key := keyPrefix
-
-				pipe.HGet(key, data.objName)
-
-				return func(result redis.Cmder) error {
-					redisValue, err := result.(*redis.StringCmd).Result()
-					if err != nil {
-						if err == redis.Nil {
-							// Return a "not found" error if this was a key.
-							if objStructRef.keyFieldIndex == data.structIndex {
-								return ErrObjectNotFound
-							}
-
-							redisValue = ""
-						} else {
-							return fmt.Errorf("%w: %s", ErrRedisCommandError, err)
-						}
-					}
-					return setFieldFromString(objValue.Field(data.structIndex), redisValue)
-				}
+	// The lengths of slices, maps and blobs are stored in the struct hash, so that reads notice their keys expiring early.
+	// Fields with a ttl tag expire on their own and are read as empty instead.
+	lengthIndex := len(objStructRef.readFieldArgs)
+	for _, collectionFields := range [][]*reflectionData{objStructRef.sliceFields, objStructRef.mapFields, objStructRef.blobFields} {
+		for _, collectionField := range collectionFields {
+			if collectionField.ttl != 0 {
+				continue
 			}
 
-			objStructRef.valueFields = append(objStructRef.valueFields, data)
-			objStructRef.fieldCount++
+			collectionField.lengthName = collectionField.objName
+			collectionField.lengthIndex = lengthIndex
+			objStructRef.hasLengths = true
 		}
 	}
+	for _, structField := range objStructRef.structFields {
+		if structField.structData.lengthName != "" {
+			structField.structData.lengthIndex = lengthIndex
+			objStructRef.hasLengths = true
+		}
+	}
+	if objStructRef.hasLengths {
+		objStructRef.readFieldArgs = append(objStructRef.readFieldArgs, lengthField)
+	}
 
 	return objStructRef, nil
 }
 
-func (self objStruct) key(keyPrefix string, objValue reflect.Value) (string, error) {
-	key := keyPrefix + ":" + self.structData.objName
+// minFieldTtl returns the shorter of two field ttls, where 0 means no ttl.
+func minFieldTtl(ttl time.Duration, fieldTtl time.Duration) time.Duration {
+	if ttl == 0 || (fieldTtl != 0 && fieldTtl < ttl) {
+		return fieldTtl
+	}
+
+	return ttl
+}
+
+// expire queues the expiry of the key of a slice or map field to the pipeline.
+// Fields tagged with a ttl expire on their own, regardless of the options.
+func (self *reflectionData) expire(pipe redis.Pipeliner, key string, options Options) {
+	if self.ttl != 0 {
+		pipe.PExpire(key, self.ttl)
+		return
+	}
+
+	options.expire(pipe, key)
+}
 
+func (self *objStruct) key(keyPrefix string, objValue reflect.Value, options Options) (string, error) {
 	if self.keyFieldIndex != -1 {
 		keyValue, err := valueToString(objValue.Field(self.keyFieldIndex))
 		if err != nil {
 			return "", err
 		}
+
+		if options.StrictKeys {
+			if keyValue == "" {
+				return "", fmt.Errorf("%w: %s key value is empty", ErrInvalidKey, self.structData.objName)
+			}
+			if !isKeyValueSafe(keyValue) {
+				return "", fmt.Errorf("%w: %s key value (%s) contains reserved characters", ErrInvalidKey, self.structData.objName, keyValue)
+			}
+		}
+
 		if keyValue == "" {
-			keyValue = "none"
+			keyValue = emptyKeyValue
+		} else {
+			keyValue = escapeKeyValue(keyValue)
 		}
 
-		key += ":" + keyValue
+		// This struct is keyed so utilize hash tags to co-locate the data on the same redis node.
+		return "{" + keyPrefix + ":" + self.structData.objName + ":" + keyValue + "}", nil
 	}
 
-	// This struct is the root or is keyed so utilize hash tags to co-locate the data on the same redis node.
-	if self.structData.structIndex == -1 || self.keyFieldIndex != -1 {
-		key = "{" + key + "}"
+	// This struct is the root so utilize hash tags to co-locate the data on the same redis node.
+	if self.structData.structIndex == -1 {
+		return "{" + keyPrefix + ":" + self.structData.objName + "}", nil
 	}
 
-	return key, nil
+	return keyPrefix + ":" + self.structData.objName, nil
 }
 
-func (self objStruct) isCacheFresh(ctx context.Context, redisClient *redis.Client, key string, objValue reflect.Value, write bool, options Options) (bool, error) {
-	if !options.EnableCaching {
-		// Caching is disabled.
-		return false, nil
-	}
-
-	// Cacheable struct are the root struct or are keyed.
-	if self.structData.structIndex != -1 && self.keyFieldIndex == -1 {
-		// Not an object to track with cache.
-		return false, nil
-	}
-
-	objHash, err := hashstructure.Hash(objValue.Interface(), hashstructure.FormatV2, nil)
-	if err != nil {
-		return false, fmt.Errorf("%w: %s", ErrCacheFailure, err)
-	}
-	hashString := strconv.FormatUint(objHash, 10)
-
-	hashKey := key + ".__HASH__"
-	var result *redis.Cmd
-	if write {
-		// When writing, update the TTL to the desired value.
-		if options.Ttl == 0 {
-			result = redisClient.WithContext(ctx).Do("SET", hashKey, hashString, "GET")
-		} else {
-			result = redisClient.WithContext(ctx).Do("SET", hashKey, hashString, "EX", strconv.Itoa(int(options.Ttl.Seconds())), "GET")
-		}
-	} else {
-		// When reading, just get the hash key.
-		result = redisClient.WithContext(ctx).Do("GET", hashKey)
+func (self *objStruct) writeExistence(pipe redis.Pipeliner, key string, options Options) {
+	if !self.isCacheable() {
+		return
 	}
 
-	previousHash, err := result.Result()
-	if err != nil && err != redis.Nil {
-		return false, fmt.Errorf("%w: %s", ErrCacheFailure, err)
+	switch {
+	case options.KeepTtl:
+		pipe.Do("set", key+".__EXISTS__", existenceMarkerExists, "keepttl")
+	case !options.ExpireAt.IsZero():
+		pipe.Set(key+".__EXISTS__", existenceMarkerExists, 0)
+		pipe.PExpireAt(key+".__EXISTS__", options.ExpireAt)
+	default:
+		pipe.Set(key+".__EXISTS__", existenceMarkerExists, options.Ttl)
 	}
-	if previousHash == nil {
-		return false, nil
-	}
-
-	cacheHit := hashString == previousHash.(string)
-
-	return cacheHit, nil
 }
 
-func (self objStruct) writeExistence(pipe redis.Pipeliner, key string, ttl time.Duration) {
-	if self.structData.structIndex == -1 || self.keyFieldIndex != -1 {
-		pipe.Set(key+".__EXISTS__", "1", ttl)
-	}
+// writeMissing records the object as missing for the ttl, replacing its existence marker with a tombstone.
+func (self *objStruct) writeMissing(pipe redis.Pipeliner, key string, ttl time.Duration) {
+	pipe.Set(key+".__EXISTS__", existenceMarkerMissing, ttl)
 }
 
-func (self objStruct) writeToRedis(ctx context.Context, redisClient *redis.Client, pipe redis.Pipeliner, keyPrefix string, objValue reflect.Value, options Options) error {
-	key, err := self.key(keyPrefix, objValue)
+// writeToRedis queues the commands writing the struct data to the pipeline.
+// Structs found fresh by the cache checks are skipped, and keep the expiry of the write that stored their data.
+func (self *objStruct) writeToRedis(pipe redis.Pipeliner, cache cacheChecks, encoder *Encoder, keyPrefix string, objValue reflect.Value, options Options) error {
+	key, err := self.key(keyPrefix, objValue, options)
 	if err != nil {
 		return err
 	}
 
-	self.writeExistence(pipe, key, options.Ttl)
-
-	if fresh, err := self.isCacheFresh(ctx, redisClient, key, objValue, true, options); err != nil {
-		return err
-	} else if fresh {
+	if cache.isFresh(key) {
 		// Do not write anything for this struct.
 		return nil
 	}
 
+	// The existence marker is written with the data, so that objects failing to write are not recorded as existing.
+	self.writeExistence(pipe, key, options)
+
 	// Delete the struct data. This is easier than trying to reconcile existing data in redis.
-	pipe.Del(key)
+	if cache == nil && self.isCacheable() {
+		// Writes without caching store no hash, so the hash of a previous write must not describe the new data.
+		pipe.Del(key, key+".__HASH__")
+	} else {
+		pipe.Del(key)
+	}
 
 	for _, structField := range self.structFields {
 		objStructValue := objValue.Field(structField.structData.structIndex)
@@ -399,64 +436,214 @@ func (self objStruct) writeToRedis(ctx context.Context, redisClient *redis.Clien
 			childKeyPrefix = key
 		}
 
-		if err := structField.writeToRedis(ctx, redisClient, pipe, childKeyPrefix, objStructValue, options); err != nil {
+		if err := structField.writeToRedis(pipe, cache, encoder, childKeyPrefix, objStructValue, options); err != nil {
 			return err
 		}
 	}
 
-	for _, valueField := range self.valueFields {
-		if err := valueField.redisWriteFn(pipe, key, objValue, options.Ttl); err != nil {
-			return err
+	if len(self.readFieldArgs) != 0 {
+		// All value fields and the lengths of non-nil slices and maps are written to the struct hash with a single command.
+		start := encoder.len()
+		for _, valueField := range self.valueFields {
+			valueField.encodeValue(encoder, objValue)
+		}
+
+		encoder.resetArgs()
+		for index, valueField := range self.valueFields {
+			encoder.args = append(encoder.args, valueField.nameArg, encoder.value(start+index))
+		}
+		if self.hasLengths {
+			// The length field is written even without lengths, as it tells objects written before lengths were stored apart.
+			self.encodeLengths(encoder, objValue)
+			encoder.args = append(encoder.args, lengthField, encoder.value(encoder.len()-1))
+		}
+
+		if len(encoder.args) != 0 {
+			pipe.HSet(key, encoder.args...)
+
+			options.expire(pipe, key)
 		}
 	}
 
 	for _, sliceField := range self.sliceFields {
-		if err := sliceField.redisWriteFn(pipe, key, objValue, options.Ttl); err != nil {
-			return err
+		start := encoder.len()
+		sliceField.encodeSlice(encoder, objValue)
+		count := encoder.len() - start
+
+		sliceKey := key + sliceField.keySuffix
+
+		if count == 0 {
+			// Remove the entries of a previous write.
+			pipe.Del(sliceKey)
+			continue
 		}
+
+		var args []interface{}
+		if sliceField.objType.Kind() == reflect.Array {
+			// Arrays are stored as lists, which keep repeated elements such as zeroed coordinates.
+			args = make([]interface{}, 2, 2+count)
+			args[0] = "rpush"
+			args[1] = sliceKey
+			for index := 0; index < count; index++ {
+				args = append(args, encoder.value(start+index))
+			}
+		} else {
+			// Slices are stored as sorted sets scored by index.
+			// ZADD is issued directly so that members do not need to be wrapped in a redis.Z each.
+			args = make([]interface{}, 2, 2+2*count)
+			args[0] = "zadd"
+			args[1] = sliceKey
+			for index := 0; index < count; index++ {
+				args = append(args, index, encoder.value(start+index))
+			}
+		}
+
+		pipe.Del(sliceKey)
+		pipe.Do(args...)
+
+		sliceField.expire(pipe, sliceKey, options)
 	}
 
 	for _, mapField := range self.mapFields {
-		if err := mapField.redisWriteFn(pipe, key, objValue, options.Ttl); err != nil {
-			return err
+		start := encoder.len()
+		mapField.encodeMap(encoder, objValue)
+		count := encoder.len() - start
+
+		mapKey := key + mapField.keySuffix
+
+		if count == 0 {
+			// Remove the entries of a previous write.
+			pipe.Del(mapKey)
+			continue
+		}
+
+		encoder.resetArgs()
+		for index := start; index < start+count; index++ {
+			encoder.args = append(encoder.args, encoder.value(index))
+		}
+
+		pipe.Del(mapKey)
+		pipe.HSet(mapKey, encoder.args...)
+
+		mapField.expire(pipe, mapKey, options)
+	}
+
+	for _, blobField := range self.blobFields {
+		blobKey := key + blobField.keySuffix
+
+		if isNil(objValue.Field(blobField.structIndex)) {
+			pipe.Del(blobKey)
+			continue
+		}
+
+		start := encoder.len()
+		blobField.encodeValue(encoder, objValue)
+
+		pipe.Set(blobKey, encoder.value(start), 0)
+
+		blobField.expire(pipe, blobKey, options)
+	}
+
+	if cache != nil && self.isCacheable() {
+		// The cache check set the hash without changing its expiry.
+		if options.Ttl == 0 && options.ExpireAt.IsZero() && !options.KeepTtl {
+			pipe.Persist(key + ".__HASH__")
+		} else {
+			options.expire(pipe, key+".__HASH__")
 		}
 	}
 
+	if options.KeepTtl && self.isCacheable() {
+		// The keys of the struct and of its nested structs without a key were written again and lost their expiry.
+		keepTtlScript.Eval(pipe, self.appendObjectTtlKeys(key, []string{key + ".__EXISTS__"}))
+	}
+
+	if cache != nil && self.isCacheable() && self.fieldTtl != 0 {
+		// The hash must not outlive the fields with a ttl, or writes after they expired would be skipped.
+		shortenTtlScript.Eval(pipe, []string{key + ".__HASH__"}, self.fieldTtl.Milliseconds())
+	}
+
 	return nil
 }
 
-func (self objStruct) readExistence(pipe redis.Pipeliner, key string, callbacks *[]readResultsCallback) {
-	if self.structData.structIndex == -1 || self.keyFieldIndex != -1 {
-		pipe.Exists(key + ".__EXISTS__")
+// isCollectionKind reports if fields of the kind are stored under keys of their own.
+func isCollectionKind(kind reflect.Kind) bool {
+	return kind == reflect.Slice || kind == reflect.Array || kind == reflect.Map
+}
 
-		*callbacks = append(*callbacks, func(result redis.Cmder) error {
-			exists, err := result.(*redis.IntCmd).Result()
-			if err != nil {
-				return fmt.Errorf("%w: %s", ErrRedisCommandError, err)
-			}
-			if exists == 0 {
-				return ErrObjectNotFound
+// isNil reports if the slice or map is nil. Arrays are never nil.
+func isNil(value reflect.Value) bool {
+	return value.Kind() != reflect.Array && value.IsNil()
+}
+
+// encodeLengths encodes the value of the length field of the struct.
+// Lengths are counted on the struct, so that they match the values encoded by generated plans as well.
+func (self *objStruct) encodeLengths(encoder *Encoder, objValue reflect.Value) {
+	start := len(encoder.buf)
+	for _, collectionFields := range [][]*reflectionData{self.sliceFields, self.mapFields, self.blobFields} {
+		for _, collectionField := range collectionFields {
+			// Nil fields are stored without a length, so that they are not read back as empty.
+			field := objValue.Field(collectionField.structIndex)
+			if collectionField.lengthName == "" || isNil(field) {
+				continue
 			}
-			return nil
-		})
+
+			encoder.buf = appendLength(encoder.buf, start, collectionField.lengthName, field.Len())
+		}
+	}
+	for _, structField := range self.structFields {
+		if structField.structData.lengthName != "" {
+			encoder.buf = appendLength(encoder.buf, start, structField.structData.lengthName, len(structField.valueFields))
+		}
+	}
+	encoder.commit(start)
+}
+
+// appendLength appends one Name=length pair to the length field value starting at start.
+func appendLength(buf []byte, start int, name string, length int) []byte {
+	if len(buf) != start {
+		buf = append(buf, ',')
 	}
+	buf = append(buf, name...)
+	buf = append(buf, '=')
+	return strconv.AppendInt(buf, int64(length), 10)
 }
 
-func (self objStruct) readFromRedis(ctx context.Context, redisClient *redis.Client, pipe redis.Pipeliner, callbacks *[]readResultsCallback, keyPrefix string, objValue reflect.Value, options Options) error {
-	key, err := self.key(keyPrefix, objValue)
+// readFromRedis queues the commands reading the struct data to the pipeline and records how to decode them in the plan.
+// Structs found fresh by the cache checks are skipped.
+// parentValuesStep is the HMGET step of the struct containing this one, holding its field count, or -1.
+func (self *objStruct) readFromRedis(pipe redis.Pipeliner, cache cacheChecks, plan *readPlan, keyPrefix string, objValue reflect.Value, parentValuesStep int, options Options) error {
+	key, err := self.key(keyPrefix, objValue, options)
 	if err != nil {
 		return err
 	}
 
-	self.readExistence(pipe, key, callbacks)
-
-	if fresh, err := self.isCacheFresh(ctx, redisClient, key, objValue, false, options); err != nil {
-		return err
-	} else if fresh {
-		// Do not write anything for this struct.
+	if cache.isFresh(key) {
+		// Do not read anything for this struct.
 		return nil
 	}
 
+	structKey := plan.key(key, "")
+
+	if self.isCacheable() {
+		// The marker is read rather than checked for existence, as it may record the object as missing.
+		pipe.Process(redis.NewStringCmd(plan.keyArgs("get", plan.key(key, ".__EXISTS__"))...))
+		plan.add(readStepExists, self, nil, objValue, structKey, -1)
+	}
+
+	valuesStep := -1
+	if len(self.readFieldArgs) != 0 {
+		// HMGET is issued with the pre-boxed field names.
+		pipe.Process(redis.NewCmd(plan.keyArgs("hmget", structKey, self.readFieldArgs...)...))
+		valuesStep = plan.add(readStepValues, self, &self.structData, objValue, structKey, parentValuesStep)
+
+		if options.Decode == DecodeStrict {
+			// Hash fields unknown to the struct are only found by listing all fields.
+			pipe.Process(redis.NewStringSliceCmd(plan.keyArgs("hkeys", structKey)...))
+			plan.add(readStepFieldNames, self, nil, objValue, structKey, -1)
+		}
+	}
+
 	for _, structField := range self.structFields {
 		objStructValue := objValue.Field(structField.structData.structIndex)
 
@@ -469,24 +656,125 @@ func (self objStruct) readFromRedis(ctx context.Context, redisClient *redis.Clie
 			childKeyPrefix = key
 		}
 
-		if err := structField.readFromRedis(ctx, redisClient, pipe, callbacks, childKeyPrefix, objStructValue, options); err != nil {
+		if err := structField.readFromRedis(pipe, cache, plan, childKeyPrefix, objStructValue, valuesStep, options); err != nil {
 			return err
 		}
 	}
 
-	for _, valueField := range self.valueFields {
-		callback := valueField.redisReadFn(pipe, key, objValue)
-		*callbacks = append(*callbacks, callback)
+	for _, sliceField := range self.sliceFields {
+		sliceKey := plan.key(key, sliceField.keySuffix)
+		if sliceField.objType.Kind() == reflect.Array {
+			pipe.Process(redis.NewStringSliceCmd(plan.keyArgs("lrange", sliceKey, "0", "-1")...))
+		} else {
+			pipe.Process(redis.NewStringSliceCmd(plan.keyArgs("zrange", sliceKey, "0", "-1")...))
+		}
+		plan.add(readStepSlice, self, sliceField, objValue, sliceKey, valuesStep)
 	}
 
-	for _, sliceField := range self.sliceFields {
-		callback := sliceField.redisReadFn(pipe, key, objValue)
-		*callbacks = append(*callbacks, callback)
+	for _, mapField := range self.mapFields {
+		mapKey := plan.key(key, mapField.keySuffix)
+		pipe.Process(redis.NewStringStringMapCmd(plan.keyArgs("hgetall", mapKey)...))
+		plan.add(readStepMap, self, mapField, objValue, mapKey, valuesStep)
+	}
+
+	for _, blobField := range self.blobFields {
+		blobKey := plan.key(key, blobField.keySuffix)
+		pipe.Process(redis.NewStringCmd(plan.keyArgs("get", blobKey)...))
+		plan.add(readStepBlob, self, blobField, objValue, blobKey, valuesStep)
 	}
 
+	return nil
+}
+
+// appendKeys appends every key of the struct stored under key, including the keys of nested structs stored with it.
+// Keyed nested structs are objects of their own, so their keys are not included.
+// All keys share the hash tag of the object, so they can be used together in one command.
+func (self *objStruct) appendKeys(key string, keys []string) []string {
+	return self.appendKeysWithTtl(key, keys, true)
+}
+
+// appendObjectTtlKeys appends the keys of appendKeys that expire with the object.
+// The keys of fields with a ttl tag, and the hash of structs containing them, expire on their own.
+func (self *objStruct) appendObjectTtlKeys(key string, keys []string) []string {
+	return self.appendKeysWithTtl(key, keys, false)
+}
+
+func (self *objStruct) appendKeysWithTtl(key string, keys []string, withFieldTtl bool) []string {
+	keys = append(keys, key)
+	if self.isCacheable() {
+		keys = append(keys, key+".__EXISTS__")
+		if withFieldTtl || self.fieldTtl == 0 {
+			keys = append(keys, key+".__HASH__")
+		}
+	}
+	for _, sliceField := range self.sliceFields {
+		if withFieldTtl || sliceField.ttl == 0 {
+			keys = append(keys, key+sliceField.keySuffix)
+		}
+	}
 	for _, mapField := range self.mapFields {
-		callback := mapField.redisReadFn(pipe, key, objValue)
-		*callbacks = append(*callbacks, callback)
+		if withFieldTtl || mapField.ttl == 0 {
+			keys = append(keys, key+mapField.keySuffix)
+		}
+	}
+	for _, blobField := range self.blobFields {
+		if withFieldTtl || blobField.ttl == 0 {
+			keys = append(keys, key+blobField.keySuffix)
+		}
+	}
+
+	for _, structField := range self.structFields {
+		if structField.keyFieldIndex != -1 {
+			continue
+		}
+
+		// The key of a nested struct without a key field does not depend on its value.
+		keys = structField.appendKeysWithTtl(key+":"+structField.structData.objName, keys, withFieldTtl)
+	}
+
+	return keys
+}
+
+// deleteFromRedis queues the deletion of every key of the object to the pipeline.
+// Keyed nested structs are objects of their own and are left alone.
+func (self *objStruct) deleteFromRedis(pipe redis.Pipeliner, keyPrefix string, objValue reflect.Value, options Options) error {
+	key, err := self.key(keyPrefix, objValue, options)
+	if err != nil {
+		return err
+	}
+
+	pipe.Del(self.appendKeys(key, nil)...)
+
+	return nil
+}
+
+// slideTtl queues the expiry of every key of the object and of its keyed nested structs to the pipeline.
+// The keys of each object only expire if the object exists, so that missing objects are not recreated or extended.
+func (self *objStruct) slideTtl(pipe redis.Pipeliner, keyPrefix string, objValue reflect.Value, ttl time.Duration, options Options) error {
+	key, err := self.key(keyPrefix, objValue, options)
+	if err != nil {
+		return err
+	}
+
+	if self.isCacheable() {
+		slideTtlScript.Eval(pipe, self.appendObjectTtlKeys(key, nil), existenceMarkerExists, ttl.Milliseconds())
+	}
+
+	for _, structField := range self.structFields {
+		objStructValue := objValue.Field(structField.structData.structIndex)
+
+		var childKeyPrefix string
+
+		// If the nested struct has a key, then treat this struct as unique data.
+		if structField.keyFieldIndex != -1 {
+			childKeyPrefix = keyPrefix
+		} else {
+			childKeyPrefix = key
+		}
+
+		if err := structField.slideTtl(pipe, childKeyPrefix, objStructValue, ttl, options); err != nil {
+			return err
+		}
 	}
 
 	return nil
@@ -0,0 +1,110 @@
+package redisobj
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+)
+
+const (
+	structTagSeparator     = ","
+	structTagValueTypeName = "type="
+	structTagValueTtl      = "ttl="
+	structTagValueBlob     = "blob"
+)
+
+// structTagOptions defines the parsed options of a redisobj struct tag.
+// Options are comma separated, for example `redisobj:"key"`, `redisobj:"type=Item"` or `redisobj:"ttl=1h"`.
+type structTagOptions struct {
+	isKey    bool
+	typeName string
+	// ttl is the expiry of the sub-key of a slice, map or blob field, overriding the expiry of the object.
+	ttl time.Duration
+	// isBlob stores a byte slice or array under a key of its own instead of in the struct hash.
+	isBlob bool
+}
+
+func parseStructTag(tag reflect.StructTag) (structTagOptions, error) {
+	tagOptions := structTagOptions{}
+
+	tagValue, exists := tag.Lookup(structTagKeyRedisobj)
+	if !exists {
+		return tagOptions, nil
+	}
+
+	for _, option := range strings.Split(tagValue, structTagSeparator) {
+		option = strings.TrimSpace(option)
+
+		switch {
+		case strings.EqualFold(option, structTagValueKey):
+			tagOptions.isKey = true
+		case strings.EqualFold(option, structTagValueBlob):
+			tagOptions.isBlob = true
+		case strings.HasPrefix(option, structTagValueTypeName):
+			tagOptions.typeName = strings.TrimPrefix(option, structTagValueTypeName)
+		case strings.HasPrefix(option, structTagValueTtl):
+			ttl, err := time.ParseDuration(strings.TrimPrefix(option, structTagValueTtl))
+			if err != nil || ttl <= 0 {
+				return tagOptions, fmt.Errorf("%w: invalid ttl option (%s)", ErrInvalidRedisDefinition, option)
+			}
+			tagOptions.ttl = ttl
+		}
+	}
+
+	return tagOptions, nil
+}
+
+// structTypeName resolves the name a struct type is stored under.
+// A registered alias takes precedence over a `redisobj:"type=Name"` tag on a blank field, which takes precedence over the type name.
+func structTypeName(objType reflect.Type, typeAliases map[reflect.Type]string) (string, error) {
+	objName, err := declaredTypeName(objType, typeAliases)
+	if err != nil {
+		return "", err
+	}
+
+	if err := validateTypeName(objName); err != nil {
+		return "", fmt.Errorf("%w (%s)", err, objType)
+	}
+
+	return objName, nil
+}
+
+// declaredTypeName resolves the name of the struct type like structTypeName, without validating it.
+// Anonymous struct types without an alias or type tag resolve to an empty name.
+func declaredTypeName(objType reflect.Type, typeAliases map[reflect.Type]string) (string, error) {
+	objName, exists := typeAliases[objType]
+	if !exists {
+		for structFieldIndex := 0; structFieldIndex < objType.NumField(); structFieldIndex++ {
+			fieldType := objType.Field(structFieldIndex)
+			if fieldType.Name == "_" {
+				tagOptions, err := parseStructTag(fieldType.Tag)
+				if err != nil {
+					return "", fmt.Errorf("%w (%s)", err, objType)
+				}
+				if tagOptions.typeName != "" {
+					objName = tagOptions.typeName
+					break
+				}
+			}
+		}
+	}
+
+	if objName == "" {
+		objName = objType.Name()
+	}
+
+	return objName, nil
+}
+
+func validateTypeName(objName string) error {
+	if objName == "" {
+		return fmt.Errorf("%w: anonymous struct types require a type name", ErrInvalidObject)
+	}
+
+	if !isKeyValueSafe(objName) {
+		return fmt.Errorf("%w: type name (%s) contains reserved characters", ErrInvalidObject, objName)
+	}
+
+	return nil
+}
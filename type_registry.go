@@ -0,0 +1,171 @@
+package redisobj
+
+import (
+	"fmt"
+	"reflect"
+	"sync"
+)
+
+// typeRegistry caches the parsed objStruct of every struct type used with a Store.
+// Types are identified by their reflect.Type so that types with the same name from different packages are not confused.
+// Lookups are lock free and each type is only parsed once, even when many goroutines use a new type at the same time.
+type typeRegistry struct {
+	objTypes sync.Map // reflect.Type -> *typeEntry
+
+	// mutex guards the type names, which are only changed while registering types.
+	mutex       sync.Mutex
+	typeAliases map[reflect.Type]string
+	typeNames   map[string]reflect.Type
+
+	// lazy allows types to be parsed and cached on first use instead of requiring registration.
+	lazy bool
+}
+
+// typeEntry is the result of parsing a type.
+// The first goroutine to use a type parses it while any other goroutines wait for ready to be closed.
+type typeEntry struct {
+	ready        chan struct{}
+	objStructRef *objStruct
+	err          error
+}
+
+func newTypeRegistry() *typeRegistry {
+	return &typeRegistry{
+		objTypes:    sync.Map{},
+		mutex:       sync.Mutex{},
+		typeAliases: map[reflect.Type]string{},
+		typeNames:   map[string]reflect.Type{},
+		lazy:        true,
+	}
+}
+
+func (self *typeRegistry) get(objType reflect.Type) (*objStruct, error) {
+	if value, exists := self.objTypes.Load(objType); exists {
+		return value.(*typeEntry).wait()
+	}
+
+	if !self.lazy {
+		return nil, fmt.Errorf("%w: %s", ErrTypeNotRegistered, objType)
+	}
+
+	return self.load(objType)
+}
+
+// register parses and caches the type, storing it under objName.
+// If objName is empty, the name is resolved the same way as lazily registered types.
+func (self *typeRegistry) register(objType reflect.Type, objName string) (*objStruct, error) {
+	if objName == "" {
+		return self.load(objType)
+	}
+
+	if err := validateTypeName(objName); err != nil {
+		return nil, err
+	}
+
+	self.mutex.Lock()
+	alias, aliased := self.typeAliases[objType]
+	if !aliased {
+		self.typeAliases[objType] = objName
+	}
+	self.mutex.Unlock()
+
+	if aliased && alias != objName {
+		return nil, fmt.Errorf("%w: %s is already registered as %s", ErrTypeNameConflict, objType, alias)
+	}
+
+	objStructRef, err := self.load(objType)
+	if err == nil && objStructRef.structData.objName != objName {
+		// The type was already in use under another name before it was registered.
+		err = fmt.Errorf("%w: %s is already registered as %s", ErrTypeNameConflict, objType, objStructRef.structData.objName)
+	}
+
+	if err != nil {
+		if !aliased {
+			self.mutex.Lock()
+			delete(self.typeAliases, objType)
+			self.mutex.Unlock()
+		}
+		return nil, err
+	}
+
+	return objStructRef, nil
+}
+
+// load returns the cached objStruct of the type, parsing the type if no other goroutine has done so yet.
+func (self *typeRegistry) load(objType reflect.Type) (*objStruct, error) {
+	entry := &typeEntry{
+		ready: make(chan struct{}),
+	}
+
+	if value, loaded := self.objTypes.LoadOrStore(objType, entry); loaded {
+		return value.(*typeEntry).wait()
+	}
+
+	entry.objStructRef, entry.err = self.parse(objType)
+	if entry.err != nil {
+		// Do not cache failures so the type may be fixed by registering it under a different name.
+		self.objTypes.Delete(objType)
+	}
+	close(entry.ready)
+
+	return entry.objStructRef, entry.err
+}
+
+// parse reflects over the type and claims the names it stores objects under.
+func (self *typeRegistry) parse(objType reflect.Type) (*objStruct, error) {
+	self.mutex.Lock()
+	typeAliases := make(map[reflect.Type]string, len(self.typeAliases))
+	for aliasType, alias := range self.typeAliases {
+		typeAliases[aliasType] = alias
+	}
+	self.mutex.Unlock()
+
+	objStructRef, err := newObjStruct(objType, "", "", typeAliases)
+	if err != nil {
+		return nil, err
+	}
+
+	self.mutex.Lock()
+	defer self.mutex.Unlock()
+
+	if err := self.claimTypeNames(objStructRef); err != nil {
+		return nil, err
+	}
+
+	return objStructRef, nil
+}
+
+// claimTypeNames ensures no two types store objects under the same top level key name.
+// The root struct and any keyed nested structs are stored at the top level of the namespace.
+// Must be called while holding the mutex.
+func (self *typeRegistry) claimTypeNames(objStructRef *objStruct) error {
+	claims := map[string]reflect.Type{}
+	collectTypeNames(objStructRef, claims)
+
+	for objName, objType := range claims {
+		if claimedType, exists := self.typeNames[objName]; exists && claimedType != objType {
+			return fmt.Errorf("%w: %s and %s are both named %s", ErrTypeNameConflict, claimedType, objType, objName)
+		}
+	}
+
+	for objName, objType := range claims {
+		self.typeNames[objName] = objType
+	}
+
+	return nil
+}
+
+func collectTypeNames(objStructRef *objStruct, claims map[string]reflect.Type) {
+	if objStructRef.structData.structIndex == -1 || objStructRef.keyFieldIndex != -1 {
+		claims[objStructRef.structData.objName] = objStructRef.structData.objType
+	}
+
+	for _, structField := range objStructRef.structFields {
+		collectTypeNames(structField, claims)
+	}
+}
+
+func (self *typeEntry) wait() (*objStruct, error) {
+	<-self.ready
+	return self.objStructRef, self.err
+}